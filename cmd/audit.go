@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Runninginsilence1/scanner/internal/globalcontext"
+	"github.com/Runninginsilence1/scanner/internal/ssh"
+)
+
+// ssh audit 弱算法审计
+
+var (
+	PolicyFile string
+	DumpPolicy bool
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "检查SSH服务器提供的弱算法",
+	Long:  `检查SSH服务器提供的密钥交换、主机密钥、加密和MAC算法, 按策略给出每台主机的问题和严重程度; 只做密钥交换, 不会尝试登录`,
+	Run: func(cmd *cobra.Command, args []string) {
+		policy := &ssh.DefaultPolicy
+		if PolicyFile != "" {
+			var err error
+			if policy, err = ssh.LoadPolicy(PolicyFile); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return
+			}
+		}
+		if DumpPolicy {
+			out, _ := yaml.Marshal(policy)
+			fmt.Print(string(out))
+			return
+		}
+
+		targets, err := parseTargets()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		option := ssh.Option{
+			Verbose:    Verbose,
+			MaxWorkers: Workers,
+			Port:       SSHPort,
+			Timeouts:   timeouts(),
+			Retry:      retryPolicy(),
+			Limiter:    rateLimiter(),
+		}
+		if OutputFormat == "console" {
+			TargetPrint(targets, SSHPort)
+		}
+		ssh.Audit(globalcontext.Ctx, targets, option, policy, OutputFormat)
+	},
+}
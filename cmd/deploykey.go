@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Runninginsilence1/scanner/internal/ssh"
+)
+
+// ssh deploy-key 安装公钥
+
+var deployKeyCmd = &cobra.Command{
+	Use:   "deploy-key <公钥文件>",
+	Short: "把公钥安装到所有成功登录的主机",
+	Long: `扫描并登录目标主机, 把公钥追加到登录用户的 ~/.ssh/authorized_keys(已存在时不重复添加, 目录权限 700, 文件权限 600),
+然后只用公钥重新登录验证; 验证使用去掉 .pub 后缀的私钥文件或者 ssh-agent 中对应的身份, 都没有时只安装不验证`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		action, err := ssh.NewDeployKeyAction(args[0], PassphraseEnv, AgentSocket)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		if !action.CanVerify() {
+			fmt.Fprintln(os.Stderr, "没有找到对应的私钥, 只安装公钥, 不验证公钥登录")
+		}
+
+		option, err := sshOption()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		option.Action = action
+		runSSHScan(option)
+	},
+}
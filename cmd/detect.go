@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/Runninginsilence1/scanner/internal/detect"
+	"github.com/Runninginsilence1/scanner/internal/globalcontext"
 	"github.com/spf13/cobra"
 )
 
@@ -18,12 +20,22 @@ var detectCmd = &cobra.Command{
 	Short: "扫描局域网内的自定义服务",
 	Long:  `用Go写了一个客户端程序，通过指定UUID环境变量和端口来查询局域网内的自定义服务。`,
 	Run: func(cmd *cobra.Command, args []string) {
+		targets, err := parseTargets()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
 		option := detect.Option{
 			EnableUUID: EnableUUID,
 			UUIDStr:    UUIDStr,
 			Port:       Port,
+			Timeouts:   timeouts(),
+			Retry:      retryPolicy(),
+			MaxWorkers: Workers,
+			Limiter:    rateLimiter(),
 		}
 		fmt.Println("Option参数", option)
-		detect.Scanner(Prefix, Start, End, option)
+		detect.Scanner(globalcontext.Ctx, targets, option)
 	},
 }
@@ -1,21 +1,65 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/spf13/cobra"
 
+	"github.com/Runninginsilence1/scanner/internal/globalcontext"
 	"github.com/Runninginsilence1/scanner/internal/ping"
+	"github.com/Runninginsilence1/scanner/internal/port"
 )
 
 // ping 网络连接
 
+var (
+	PingCount    int
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+	PingMethod   string
+	PingPorts    string
+	ShowFailed   bool
+)
+
 var pingCmd = &cobra.Command{
 	Use:   "ping",
 	Short: "扫描局域网内的ping服务",
-	Long:  `扫描局域网内的ping服务, 检测主机是否存活`,
+	Long:  `扫描局域网内的ping服务, 检测主机是否存活; 主机丢弃 ICMP 时可以用 --method tcp 或 auto 通过 TCP 连接判断`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if !slices.Contains(ping.Methods, PingMethod) {
+			fmt.Fprintf(os.Stderr, "unknown ping method %q, available: %s\n", PingMethod, strings.Join(ping.Methods, ", "))
+			return
+		}
+		ports, err := port.ParsePorts(PingPorts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		targets, err := parseTargets()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
 		if OutputFormat == "default" {
-			SSHPrint()
+			TargetPrint(targets, 0)
+		}
+		option := ping.Option{
+			MaxWorkers: Workers,
+			Limiter:    rateLimiter(),
+			ShowFailed: ShowFailed,
+			Method:     PingMethod,
+			Ports:      ports,
+			Count:      PingCount,
+			Interval:   PingInterval,
+			Timeout:    PingTimeout,
+			Timeouts:   timeouts(),
+			Retry:      retryPolicy(),
 		}
-		ping.Parallel(Prefix, Start, End, OutputFormat)
+		ping.Parallel(globalcontext.Ctx, targets, option, OutputFormat)
 	},
 }
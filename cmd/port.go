@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Runninginsilence1/scanner/internal/globalcontext"
+	"github.com/Runninginsilence1/scanner/internal/port"
+)
+
+// port 端口扫描
+
+var (
+	Ports      string
+	TopPorts   int
+	ShowClosed bool
+)
+
+var portCmd = &cobra.Command{
+	Use:   "port",
+	Short: "扫描局域网内主机开放的TCP端口",
+	Long:  `扫描局域网内主机开放的TCP端口, 端口可以用 --ports 指定列表或范围, 也可以用 --top 扫描最常见的端口`,
+	Run: func(cmd *cobra.Command, args []string) {
+		targets, err := parseTargets()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		ports, err := parsePorts()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		option := port.Option{
+			ShowClosed: ShowClosed,
+			MaxWorkers: Workers,
+			Limiter:    rateLimiter(),
+			Timeouts:   timeouts(),
+			Retry:      retryPolicy(),
+		}
+		if OutputFormat == "console" {
+			TargetPrint(targets, 0)
+			fmt.Printf("扫描端口: %d 个\n\n", len(ports))
+		}
+		port.Run(globalcontext.Ctx, targets, ports, option, OutputFormat)
+	},
+}
+
+// parsePorts 合并 --ports 和 --top 指定的端口, 都未指定时扫描最常见的 100 个端口
+func parsePorts() ([]int, error) {
+	if Ports == "" && TopPorts == 0 {
+		TopPorts = port.MaxTop
+	}
+
+	var lists [][]int
+	if Ports != "" {
+		ports, err := port.ParsePorts(Ports)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, ports)
+	}
+	if TopPorts != 0 {
+		ports, err := port.TopPorts(TopPorts)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, ports)
+	}
+	return port.Merge(lists...), nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Runninginsilence1/scanner/internal/ssh"
+)
+
+// ssh push 上传文件
+
+var PushMode string
+
+var pushCmd = &cobra.Command{
+	Use:   "push <本地文件> <远程路径>",
+	Short: "把文件上传到所有成功登录的主机",
+	Long:  `扫描并登录目标主机, 通过 SCP 把本地文件上传到每台成功登录的主机; 远程路径是已有的目录或以 / 结尾时使用本地文件名`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var mode uint64
+		if PushMode != "" {
+			var err error
+			if mode, err = strconv.ParseUint(PushMode, 8, 32); err != nil || mode > 0o777 {
+				fmt.Fprintf(os.Stderr, "invalid mode %q, should be octal like 0644\n", PushMode)
+				return
+			}
+		}
+
+		action, err := ssh.NewPushAction(args[0], args[1], os.FileMode(mode))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		option, err := sshOption()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		option.Action = action
+		runSSHScan(option)
+	},
+}
@@ -3,10 +3,19 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/Runninginsilence1/scanner/internal/dumper"
+	"github.com/Runninginsilence1/scanner/internal/limiter"
+	"github.com/Runninginsilence1/scanner/internal/ping"
+	"github.com/Runninginsilence1/scanner/internal/port"
+	"github.com/Runninginsilence1/scanner/internal/retry"
+	"github.com/Runninginsilence1/scanner/internal/ssh"
+	"github.com/Runninginsilence1/scanner/internal/target"
+	"github.com/Runninginsilence1/scanner/internal/timeout"
 )
 
 // print options
@@ -19,11 +28,31 @@ var (
 
 // args for rootCmd
 var (
-	Prefix   int    // 网段
-	Start    int    // 起始IP
-	End      int    // 结束IP
-	User     string // 用户名
-	Password string // 密码
+	Target      string // 扫描目标, 为空时使用 Prefix/Start/End
+	TargetsFile string // 扫描目标文件, "-" 表示标准输入
+	Prefix      int    // 网段
+	Start       int    // 起始IP
+	End         int    // 结束IP
+	User        string // 用户名
+	Password    string // 密码
+)
+
+// args for timeouts, 0 表示使用各扫描器的默认值
+var (
+	ConnectTimeout   time.Duration
+	HandshakeTimeout time.Duration
+	CommandTimeout   time.Duration
+	AdaptiveTimeout  bool
+
+	Retries      int           // 网络错误的重试次数
+	RetryBackoff time.Duration // 第一次重试前的等待时间
+)
+
+// args for concurrency and rate limiting, 0 表示使用默认值或不限制
+var (
+	Workers    int // 最大并发数
+	Rate       int // 每秒最多发起的连接数
+	MaxPerHost int // 每台主机最多同时进行的连接数
 )
 
 // arg for output format
@@ -48,6 +77,10 @@ func init() {
 	// PersistentFlags: 全局参数, 所有子命令都可以使用
 	// Flags: 局部参数, 只能在当前命令中使用
 	{
+		rootCmd.PersistentFlags().
+			StringVarP(&Target, "target", "t", "", "扫描目标, 支持 CIDR(10.20.0.0/16, 2001:db8::/120)、范围(10.0.0.5-10.0.1.20)、单个IP(含 IPv6 和 fe80::1%eth0)和主机名, 多个用逗号分隔; 为空时使用 --prefix/--start/--end")
+		rootCmd.PersistentFlags().
+			StringVarP(&TargetsFile, "targets-file", "", "", "从文件读取扫描目标, 每行一个, 支持 # 注释和 host:port 单独指定端口; - 表示标准输入")
 		rootCmd.PersistentFlags().
 			IntVarP(&Prefix, "prefix", "p", 3, "网段, 例如 3")
 		rootCmd.PersistentFlags().
@@ -58,6 +91,24 @@ func init() {
 			StringVarP(&OutputFormat, "output-format", "", "console", "输出格式, 可选:"+dumper.GetAllTypeString())
 		rootCmd.PersistentFlags().
 			BoolVarP(&Verbose, "verbose", "v", false, "显示详细信息")
+		rootCmd.PersistentFlags().
+			DurationVarP(&ConnectTimeout, "connect-timeout", "", 0, "TCP 连接超时, 例如 2s; 默认 ssh 为 500ms, port 和 detect 为 1s")
+		rootCmd.PersistentFlags().
+			DurationVarP(&HandshakeTimeout, "handshake-timeout", "", 0, "握手超时: ssh 的握手和认证(默认 10s), detect 的 HTTP 请求(默认 1s)")
+		rootCmd.PersistentFlags().
+			DurationVarP(&CommandTimeout, "command-timeout", "", 0, "远程命令超时, 包括 --exec、收集主机信息和 ssh 子命令的操作, 默认 10s")
+		rootCmd.PersistentFlags().
+			BoolVarP(&AdaptiveTimeout, "adaptive-timeout", "", false, "按子网(IPv4 /24, IPv6 /64)测量连接的往返时间, 在慢速链路上自动放宽超时, 在局域网上缩短连接超时")
+		rootCmd.PersistentFlags().
+			IntVarP(&Retries, "retries", "", 0, "连接超时、连接被重置等暂时性网络错误的重试次数; 认证失败不会重试")
+		rootCmd.PersistentFlags().
+			DurationVarP(&RetryBackoff, "retry-backoff", "", 200*time.Millisecond, "第一次重试前的等待时间, 之后每次翻倍(最多 30s)")
+		rootCmd.PersistentFlags().
+			IntVarP(&Workers, "workers", "", 0, "最大并发数, 0 表示使用默认值 500")
+		rootCmd.PersistentFlags().
+			IntVarP(&Rate, "rate", "", 0, "所有扫描器共用的速率限制, 每秒最多发起的连接数(包括重试和每个凭据的尝试), 0 表示不限速")
+		rootCmd.PersistentFlags().
+			IntVarP(&MaxPerHost, "max-per-host", "", 0, "每台主机最多同时进行的连接数(只计算连接和握手阶段), 0 表示不限制")
 	}
 
 	{
@@ -65,15 +116,71 @@ func init() {
 			StringVarP(&User, "user", "u", "root", "用户名, 例如 root")
 		sshCmd.PersistentFlags().
 			StringVarP(&Password, "password", "P", "123456", "密码, 例如 123456.当启用公钥登录(--pubkey)的时候无效")
-		sshCmd.Flags().
+		sshCmd.PersistentFlags().
 			IntVarP(&SSHPort, "port", "", 22, "SSH端口, 默认22")
-		sshCmd.Flags().
+		sshCmd.PersistentFlags().
 			BoolVarP(&NetworkFailed, "network", "n", false, "是否显示因为网络错误而失败的IP")
-		sshCmd.Flags().
+		sshCmd.PersistentFlags().
 			BoolVarP(&AuthenticationFailed, "auth", "a", false, "是否显示因为认证错误而失败的IP")
-		sshCmd.Flags().
+		sshCmd.PersistentFlags().
 			BoolVarP(&EnablePubKey, "pubkey", "", false, "只允许启用公钥登录")
+		sshCmd.PersistentFlags().
+			StringArrayVarP(&Identities, "identity", "i", nil, "私钥文件, 可以重复指定, 指定后自动启用公钥登录; 未指定时依次查找 ~/.ssh/id_ed25519, id_ecdsa, id_rsa")
+		sshCmd.PersistentFlags().
+			StringVarP(&PassphraseEnv, "identity-passphrase-env", "", "", "保存私钥密码的环境变量名, 未指定时在终端提示输入")
+		sshCmd.PersistentFlags().
+			BoolVarP(&UseAgent, "agent", "", false, "通过 ssh-agent 进行公钥登录, 依次尝试 agent 中的所有身份")
+		sshCmd.PersistentFlags().
+			StringVarP(&AgentSocket, "agent-socket", "", "", "ssh-agent 的 unix socket 路径, 默认使用 SSH_AUTH_SOCK")
+		sshCmd.PersistentFlags().
+			StringSliceVarP(&AuthMethods, "auth-methods", "", nil, "按顺序尝试的认证方式, 可选 publickey, password, keyboard-interactive, 例如 publickey,password; 默认为 password,keyboard-interactive, 指定了 --pubkey/--identity/--agent 时只使用公钥")
 		sshCmd.Flags().BoolVarP(&Loop, "loop", "l", false, "是否启用循环检索模式")
+		sshCmd.PersistentFlags().
+			StringVarP(&KnownHostsFile, "known-hosts", "", "", "用 known_hosts 文件校验主机密钥, 标记新增、变化和一致的主机, 例如 ~/.ssh/known_hosts")
+		sshCmd.Flags().
+			StringVarP(&WriteKnownHostsFile, "write-known-hosts", "", "", "扫描结束后把扫描到的主机密钥写入该 known_hosts 文件(覆盖)")
+		sshCmd.PersistentFlags().
+			StringVarP(&UsersFile, "users-file", "", "", "用户名列表文件, 每行一个, 与密码逐一组合尝试")
+		sshCmd.PersistentFlags().
+			StringVarP(&PasswordsFile, "passwords-file", "", "", "密码列表文件, 每行一个, 与用户名逐一组合尝试")
+		sshCmd.PersistentFlags().
+			StringVarP(&ComboFile, "combo-file", "", "", "user:pass 凭据文件, 每行一个, 优先于用户名/密码列表尝试")
+		sshCmd.PersistentFlags().
+			IntVarP(&MaxAttempts, "max-attempts", "", 0, "每台主机最多尝试的凭据数, 0 表示不限制")
+		sshCmd.PersistentFlags().
+			DurationVarP(&AttemptDelay, "attempt-delay", "", 0, "同一台主机两次尝试之间的间隔, 例如 500ms, 避免触发 fail2ban")
+		sshCmd.Flags().
+			StringVarP(&Exec, "exec", "", "", "登录成功后执行的命令, 例如 \"hostname; uname -a\", 结果中包含 stdout、stderr 和退出码")
+		sshCmd.Flags().
+			BoolVarP(&Facts, "facts", "", false, "登录成功后收集主机信息: 主机名、系统、内核、架构、运行时间、MAC 地址、内存和磁盘容量")
+	}
+
+	// auditCmd的参数
+	{
+		auditCmd.Flags().
+			StringVarP(&PolicyFile, "policy", "", "", "YAML 或 JSON 格式的审计策略文件, 默认使用内置策略")
+		auditCmd.Flags().
+			BoolVarP(&DumpPolicy, "dump-policy", "", false, "输出当前使用的审计策略(YAML), 可以作为自定义策略的模板")
+	}
+
+	// pushCmd的参数
+	{
+		pushCmd.Flags().
+			StringVarP(&PushMode, "mode", "", "", "远程文件的权限, 八进制, 例如 0644; 默认与本地文件相同")
+	}
+
+	// rotatePasswordCmd的参数
+	{
+		rotatePasswordCmd.Flags().
+			StringVarP(&NewPassword, "new-password", "", "", "新密码")
+		rotatePasswordCmd.Flags().
+			StringVarP(&NewPasswordEnv, "new-password-env", "", "", "保存新密码的环境变量名, 优先于 --new-password, 避免密码出现在命令行历史中")
+		rotatePasswordCmd.Flags().
+			BoolVarP(&RotateConfirm, "confirm", "", false, "确认修改密码, 不指定时拒绝执行")
+		rotatePasswordCmd.Flags().
+			BoolVarP(&RotateDryRun, "dry-run", "", false, "只登录并列出将要修改密码的主机, 不做修改")
+		rotatePasswordCmd.Flags().
+			StringVarP(&RotateAuditLog, "audit-log", "", "rotate-password-audit.jsonl", "审计记录文件, 每台主机追加一行 JSON")
 	}
 
 	// detectCmd的参数
@@ -86,30 +193,121 @@ func init() {
 			IntVarP(&Port, "port", "", 8080, "自定义服务端的端口，默认8080")
 	}
 
+	// pingCmd的参数
+	{
+		pingCmd.Flags().
+			StringVarP(&PingMethod, "method", "", ping.MethodICMP, "探测方式, 可选 icmp, tcp(连接 --tcp-ports, 连接成功或被拒绝都认为存活), auto(先 icmp, 没有应答时再 tcp)")
+		pingCmd.Flags().
+			StringVarP(&PingPorts, "tcp-ports", "", "22,80,443", "TCP 探测的端口列表, 例如 22,80,443,3389")
+		pingCmd.Flags().
+			IntVarP(&PingCount, "count", "c", 4, "每台主机发送的 ICMP echo 请求数")
+		pingCmd.Flags().
+			DurationVarP(&PingInterval, "interval", "", time.Second, "两个请求之间的间隔")
+		pingCmd.Flags().
+			DurationVarP(&PingTimeout, "timeout", "", 0, "每个 ICMP 请求等待应答的时间(默认 1s); 指定时也是 TCP 探测的连接超时, 否则 TCP 探测使用 --connect-timeout(默认 1s)")
+		pingCmd.Flags().
+			BoolVarP(&ShowFailed, "failed", "", false, "是否显示没有应答的主机, JSON 输出中包含 fail_list 和这些主机的统计")
+	}
+
+	// portCmd的参数
+	{
+		portCmd.Flags().
+			StringVarP(&Ports, "ports", "", "", "端口列表, 例如 22,80,443,8000-8100")
+		portCmd.Flags().
+			IntVarP(&TopPorts, "top", "", 0, fmt.Sprintf("扫描最常见的 N 个端口(1-%d), 与 --ports 同时指定时取并集; 都不指定时扫描最常见的 %d 个端口", port.MaxTop, port.MaxTop))
+		portCmd.Flags().
+			BoolVarP(&ShowClosed, "closed", "", false, "是否显示关闭的端口")
+	}
+
 	{
 		rootCmd.AddCommand(sshCmd)
+		sshCmd.AddCommand(auditCmd)
+		sshCmd.AddCommand(pushCmd)
+		sshCmd.AddCommand(deployKeyCmd)
+		sshCmd.AddCommand(rotatePasswordCmd)
 		rootCmd.AddCommand(pingCmd)
 		rootCmd.AddCommand(detectCmd)
+		rootCmd.AddCommand(portCmd)
 	}
 }
 
+// timeouts 根据超时参数生成所有扫描器共用的超时设置
+func timeouts() *timeout.Config {
+	return timeout.New(ConnectTimeout, HandshakeTimeout, CommandTimeout, AdaptiveTimeout)
+}
+
+// rateLimiter 根据 --rate 和 --max-per-host 生成所有 worker 共享的限速器
+func rateLimiter() *limiter.Limiter {
+	return limiter.New(Rate, MaxPerHost)
+}
+
+// retryPolicy 根据重试参数生成所有扫描器共用的重试策略
+func retryPolicy() *retry.Policy {
+	return retry.New(Retries, RetryBackoff)
+}
+
 func Execute() error {
 	return rootCmd.Execute()
 }
 
-func SSHPrint() {
-	fmt.Printf(
-		"扫描范围: 192.168.%d.%d 到 192.168.%d.%d\n",
-		Prefix,
-		Start,
-		Prefix,
-		End,
-	)
-	fmt.Printf("登录用户名: %s\n", User)
-	fmt.Printf("登录密码: %s\n", Password)
-	if EnablePubKey {
-		fmt.Println("启用公钥登录")
+// parseTargets 解析扫描目标, --target 和 --targets-file 可以同时使用,
+// 都未指定时使用旧的 --prefix/--start/--end 参数
+func parseTargets() (*target.List, error) {
+	if Target == "" && TargetsFile == "" {
+		return target.FromLegacy(Prefix, Start, End)
+	}
+
+	targets := &target.List{}
+	if TargetsFile != "" {
+		fileTargets, err := readTargetsFile(TargetsFile)
+		if err != nil {
+			return nil, err
+		}
+		targets.Merge(fileTargets)
+	}
+	if Target != "" {
+		specTargets, err := target.Parse(Target)
+		if err != nil {
+			return nil, err
+		}
+		targets.Merge(specTargets)
+	}
+	return targets, nil
+}
+
+func readTargetsFile(path string) (*target.List, error) {
+	if path == "-" {
+		return target.ParseReader(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open targets file: %w", err)
+	}
+	defer f.Close()
+
+	targets, err := target.ParseReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return targets, nil
+}
+
+func TargetPrint(targets *target.List, defaultPort int) {
+	fmt.Printf("扫描范围: %s (共 %d 个)\n", targets, targets.Len(defaultPort))
+}
+
+func SSHPrint(targets *target.List, creds []ssh.Credential, auth ssh.Auth) {
+	TargetPrint(targets, SSHPort)
+	if len(creds) == 1 {
+		fmt.Printf("登录用户名: %s\n", creds[0].User)
+		fmt.Printf("登录密码: %s\n", creds[0].Password)
+	} else {
+		fmt.Printf("登录凭据: %d 组\n", len(creds))
+	}
+	if MaxAttempts > 0 {
+		fmt.Printf("每台主机最多尝试: %d 次\n", MaxAttempts)
 	}
+	fmt.Printf("认证方式: %s\n", strings.Join(auth.Methods, ", "))
 	fmt.Println()
 	//fmt.Println("IP List:")
 }
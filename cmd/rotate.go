@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Runninginsilence1/scanner/internal/ssh"
+)
+
+// ssh rotate-password 批量修改密码
+
+var (
+	NewPassword    string
+	NewPasswordEnv string
+	RotateConfirm  bool
+	RotateDryRun   bool
+	RotateAuditLog string
+)
+
+var rotatePasswordCmd = &cobra.Command{
+	Use:   "rotate-password",
+	Short: "把所有成功登录的主机的密码修改为新密码",
+	Long: `扫描并用当前密码登录目标主机, 把登录用户的密码修改为新密码(root 使用 chpasswd, 其他用户使用 passwd), 然后用新密码重新登录验证;
+每台主机的结果都会追加到审计记录文件中(不包含密码). 必须指定 --confirm 才会真正修改, --dry-run 只列出将要修改的主机`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !RotateConfirm && !RotateDryRun {
+			fmt.Fprintln(os.Stderr, "修改密码需要指定 --confirm, 可以先用 --dry-run 查看将要修改的主机")
+			return
+		}
+
+		newPassword, err := loadNewPassword()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		action, err := ssh.NewRotatePasswordAction(newPassword, RotateDryRun, RotateAuditLog)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		defer action.Close()
+
+		option, err := sshOption()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		option.Action = action
+		runSSHScan(option)
+		fmt.Printf("审计记录: %s\n", RotateAuditLog)
+	},
+}
+
+// loadNewPassword 从 --new-password 或 --new-password-env 读取新密码
+func loadNewPassword() (string, error) {
+	if NewPasswordEnv == "" {
+		if NewPassword == "" {
+			return "", errors.New("new password is required, use --new-password or --new-password-env")
+		}
+		return NewPassword, nil
+	}
+	password, ok := os.LookupEnv(NewPasswordEnv)
+	if !ok {
+		return "", fmt.Errorf("$%s is not set", NewPasswordEnv)
+	}
+	return password, nil
+}
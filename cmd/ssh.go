@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/spf13/cobra"
 
 	"github.com/Runninginsilence1/scanner/internal/globalcontext"
@@ -12,6 +16,24 @@ import (
 var (
 	EnablePubKey bool
 	SSHPort      int
+
+	UsersFile     string
+	PasswordsFile string
+	ComboFile     string
+	MaxAttempts   int
+	AttemptDelay  time.Duration
+
+	Identities    []string
+	PassphraseEnv string
+	UseAgent      bool
+	AgentSocket   string
+	AuthMethods   []string
+
+	KnownHostsFile      string
+	WriteKnownHostsFile string
+
+	Exec  string
+	Facts bool
 )
 
 var sshCmd = &cobra.Command{
@@ -19,26 +41,110 @@ var sshCmd = &cobra.Command{
 	Short: "扫描局域网内的SSH服务并尝试密码或密钥登录",
 	Long:  `扫描局域网内的SSH服务并尝试密码或密钥登录`,
 	Run: func(cmd *cobra.Command, args []string) {
-		option := ssh.Option{
-			ShowAuth:     AuthenticationFailed,
-			ShowNetwork:  NetworkFailed,
-			EnablePubKey: EnablePubKey,
-			Verbose:      Verbose,
-			Loop:         Loop,
-			MaxWorkers:   0, // 使用默认值 500
-			Port:         SSHPort,
+		option, err := sshOption()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
 		}
+		option.Loop = Loop
+		option.WriteKnownHosts = WriteKnownHostsFile
+		option.Exec = Exec
+		option.Facts = Facts
+		runSSHScan(option)
+	},
+}
+
+// sshOption 根据 ssh 命令及其子命令共用的参数生成扫描选项
+func sshOption() (ssh.Option, error) {
+	option := ssh.Option{
+		ShowAuth:     AuthenticationFailed,
+		ShowNetwork:  NetworkFailed,
+		Verbose:      Verbose,
+		MaxWorkers:   Workers,
+		Port:         SSHPort,
+		MaxAttempts:  MaxAttempts,
+		AttemptDelay: AttemptDelay,
+		Timeouts:     timeouts(),
+		Retry:        retryPolicy(),
+		Limiter:      rateLimiter(),
+	}
 
-		// 如果是 console 输出格式且不是 verbose 模式，使用 bubbletea
-		if OutputFormat == "console" && !Verbose {
-			SSHPrint()
-			ssh.ScannerWithTea(globalcontext.Ctx, Prefix, Start, End, User, Password, option, OutputFormat)
-		} else {
-			// 其他情况使用原来的扫描器
-			if OutputFormat == "default" {
-				SSHPrint()
-			}
-			ssh.ScannerV2(globalcontext.Ctx, Prefix, Start, End, User, Password, option, OutputFormat)
+	var err error
+	if option.Auth, err = loadAuth(); err != nil {
+		return option, err
+	}
+	if KnownHostsFile != "" {
+		if option.KnownHosts, err = ssh.LoadKnownHosts(KnownHostsFile); err != nil {
+			return option, err
 		}
-	},
+	}
+	return option, nil
+}
+
+// runSSHScan 扫描并登录目标主机, 对成功登录的主机执行 option 中指定的操作
+func runSSHScan(option ssh.Option) {
+	targets, err := parseTargets()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	// 如果是 console 输出格式且不是 verbose 模式，使用 bubbletea
+	if OutputFormat == "console" && !Verbose {
+		SSHPrint(targets, creds, option.Auth)
+		ssh.ScannerWithTea(globalcontext.Ctx, targets, creds, option, OutputFormat)
+	} else {
+		// 其他情况使用原来的扫描器
+		if OutputFormat == "default" {
+			SSHPrint(targets, creds, option.Auth)
+		}
+		ssh.ScannerV2(globalcontext.Ctx, targets, creds, option, OutputFormat)
+	}
+}
+
+// loadCredentials 根据 -u/-P 和凭据文件生成要尝试的凭据列表
+func loadCredentials() ([]ssh.Credential, error) {
+	return ssh.LoadCredentials(ssh.CredentialSource{
+		User:          User,
+		Password:      Password,
+		UsersFile:     UsersFile,
+		PasswordsFile: PasswordsFile,
+		ComboFile:     ComboFile,
+	})
+}
+
+// loadAuth 确定认证方式, 启用公钥登录时读取私钥
+// 未指定 --auth-methods 时, 指定了 --pubkey、--identity 或 --agent 则只使用公钥登录, 否则使用默认的认证方式(密码和 keyboard-interactive)
+func loadAuth() (ssh.Auth, error) {
+	methods := AuthMethods
+	if len(methods) == 0 {
+		methods = ssh.DefaultMethods
+		if EnablePubKey || len(Identities) > 0 || UseAgent {
+			methods = []string{ssh.MethodPublicKey}
+		}
+	}
+
+	var (
+		auth ssh.Auth
+		err  error
+	)
+	if auth.Methods, err = ssh.ParseMethods(methods); err != nil {
+		return auth, err
+	}
+	if !auth.Has(ssh.MethodPublicKey) {
+		return auth, nil
+	}
+	auth.Signers, err = ssh.LoadSigners(ssh.IdentityOption{
+		Files:         Identities,
+		PassphraseEnv: PassphraseEnv,
+		UseAgent:      UseAgent,
+		AgentSocket:   AgentSocket,
+	})
+	return auth, err
 }
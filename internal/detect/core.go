@@ -1,67 +1,120 @@
 package detect
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/Runninginsilence1/scanner/internal/ip_gen"
 	"github.com/imroc/req/v3"
+
+	"github.com/Runninginsilence1/scanner/internal/target"
 )
 
-func Scanner(prefix, start, end int, opt Option) {
+func Scanner(ctx context.Context, targets *target.List, opt Option) {
 	calTime := time.Now()
 	defer func() {
 		fmt.Printf("扫描完成, 用时: %v ms\n", time.Now().Sub(calTime).Milliseconds())
 	}()
-	var wg sync.WaitGroup
-	taskNum := end - start + 1
-	wg.Add(taskNum)
 
-	var (
-		doneChan = make(chan struct{})
-	)
+	// 设置默认并发数
+	maxWorkers := opt.MaxWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = 500
+	}
 
-	for i := start; i <= end; i++ {
-		go func(suffix int) {
-			defer wg.Done()
-			addr := ip_gen.GetAddr(prefix, suffix, opt.Port)
+	// 创建任务队列
+	taskCh := make(chan string, 100)
+	var wg sync.WaitGroup
 
-			ok := detect(addr, opt.UUIDStr, opt.EnableUUID)
-			if ok {
-				fmt.Println(addr)
+	// 启动 worker pool
+	for w := 0; w < maxWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range taskCh {
+				retries, ok := detectRetry(ctx, addr, opt)
+				switch {
+				case ctx.Err() != nil:
+					return
+				case ok && retries > 0:
+					fmt.Printf("%s\t重试 %d 次\n", addr, retries)
+				case ok:
+					fmt.Println(addr)
+				}
 			}
-		}(i)
+		}()
 	}
+
+	// 发送任务到任务队列
+	go func() {
+		defer close(taskCh)
+		for t := range targets.All(opt.Port) {
+			select {
+			case <-ctx.Done():
+				return
+			case taskCh <- t.Addr(opt.Port):
+			}
+		}
+	}()
+
 	wg.Wait()
-	close(doneChan)
 }
 
-// 超时1秒
-func detect(address string, uuidStr string, enableUUID bool) bool {
+// 默认连接和整个请求的超时都是1秒
+const defaultTimeout = time.Second
+
+// detectRetry 检测自定义服务, 连接超时或被重置时按 opt.Retry 重试, 返回重试的次数
+func detectRetry(ctx context.Context, address string, opt Option) (int, bool) {
+	ok := false
+	retries, _ := opt.Retry.Do(ctx, func() error {
+		var err error
+		ok, err = detect(ctx, address, opt)
+		return err
+	}, func(err error) bool {
+		var netErr net.Error
+		return (errors.As(err, &netErr) && netErr.Timeout()) || errors.Is(err, syscall.ECONNRESET)
+	})
+	return retries, ok
+}
+
+// detect 返回的错误是请求失败的原因, 服务有响应但 UUID 不匹配时返回 false 和 nil
+func detect(ctx context.Context, address string, opt Option) (bool, error) {
 	// 和 exec包的Cmd不同，http状态码不会影响到错误
 
+	host, _, _ := net.SplitHostPort(address)
+	release, err := opt.Limiter.Acquire(ctx, host)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	timeouts := opt.Timeouts
 	cli := req.C()
-	cli.SetBaseURL("http://" + address)
-	cli.SetTimeout(1 * time.Second)
+	// 用 url.URL 拼接, IPv6 zone 中的 % 需要转义
+	cli.SetBaseURL((&url.URL{Scheme: "http", Host: address}).String())
+	cli.SetDial((&net.Dialer{Timeout: timeouts.ConnectTimeout(host, defaultTimeout)}).DialContext)
+	cli.SetTimeout(timeouts.HandshakeTimeout(host, defaultTimeout))
 
 	get, err := cli.R().
+		SetContext(ctx).
 		SetQueryParam("page", "1").
 		SetQueryParam("page_size", "10").
 		Get("/")
 
 	if err != nil {
-		return false
+		return false, err
 	}
 
 	// 如果没有特殊要求，只要路由程序有响应就返回true
-	if !enableUUID {
-		return true
+	if !opt.EnableUUID {
+		return true, nil
 	}
 	targetUUIDStr := get.String()
-	if strings.EqualFold(uuidStr, targetUUIDStr) {
-		return true
-	}
-	return false
+	return strings.EqualFold(opt.UUIDStr, targetUUIDStr), nil
 }
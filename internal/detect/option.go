@@ -1,5 +1,11 @@
 package detect
 
+import (
+	"github.com/Runninginsilence1/scanner/internal/limiter"
+	"github.com/Runninginsilence1/scanner/internal/retry"
+	"github.com/Runninginsilence1/scanner/internal/timeout"
+)
+
 type Option struct {
 	//ShowNetwork bool
 	//ShowAuth    bool
@@ -9,4 +15,10 @@ type Option struct {
 	UUIDStr    string
 	EnableUUID bool
 	Port       int
+
+	Timeouts *timeout.Config // 超时设置, 为 nil 时使用默认值
+	Retry    *retry.Policy   // 网络错误的重试策略, 为 nil 时不重试
+
+	MaxWorkers int              // 最大并发数，默认 500
+	Limiter    *limiter.Limiter // 连接速率和每台主机的并发限制, 为 nil 时不限速
 }
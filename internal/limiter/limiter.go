@@ -0,0 +1,112 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter 是所有 worker 共享的限速器:
+//   - 令牌桶限制每秒发起的连接数, 桶容量为 1, 即连接会被均匀地分散开
+//   - 限制同一台主机上同时进行的连接数
+//
+// nil 表示不限速
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration // 为 0 时不限制速率
+	next     time.Time
+
+	maxPerHost int // 为 0 时不限制每台主机的并发数
+	hosts      map[string]*hostSlots
+}
+
+// hostSlots 是一台主机的并发槽位, refs 为 0 时从 map 中删除, 避免扫描大网段时占用内存
+type hostSlots struct {
+	sem  chan struct{}
+	refs int
+}
+
+// New 创建每秒最多放行 rate 个连接、每台主机最多同时 maxPerHost 个连接的限速器,
+// 两个参数都 <= 0 时返回 nil(不限速)
+func New(rate, maxPerHost int) *Limiter {
+	if rate <= 0 && maxPerHost <= 0 {
+		return nil
+	}
+	l := &Limiter{maxPerHost: max(maxPerHost, 0), hosts: make(map[string]*hostSlots)}
+	if rate > 0 {
+		l.interval = time.Second / time.Duration(rate)
+	}
+	return l
+}
+
+// Wait 阻塞直到拿到令牌或 context 被取消
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil || l.interval == 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Acquire 等待 host 上的空闲槽位和一个令牌, 成功时返回的 release 必须在连接结束后调用
+func (l *Limiter) Acquire(ctx context.Context, host string) (release func(), err error) {
+	release = func() {}
+	if l != nil && l.maxPerHost > 0 {
+		slots := l.ref(host)
+		select {
+		case slots.sem <- struct{}{}:
+		case <-ctx.Done():
+			l.unref(host, slots)
+			return nil, ctx.Err()
+		}
+		release = func() {
+			<-slots.sem
+			l.unref(host, slots)
+		}
+	}
+	if err = l.Wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+func (l *Limiter) ref(host string) *hostSlots {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots, ok := l.hosts[host]
+	if !ok {
+		slots = &hostSlots{sem: make(chan struct{}, l.maxPerHost)}
+		l.hosts[host] = slots
+	}
+	slots.refs++
+	return slots
+}
+
+func (l *Limiter) unref(host string, slots *hostSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots.refs--
+	if slots.refs == 0 {
+		delete(l.hosts, host)
+	}
+}
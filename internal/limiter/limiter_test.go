@@ -0,0 +1,159 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// hostCount 返回还在 map 中的主机数
+func (l *Limiter) hostCount() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.hosts)
+}
+
+func TestRate(t *testing.T) {
+	l := New(20, 0)
+	if l.interval != 50*time.Millisecond {
+		t.Fatalf("interval = %v, want 50ms", l.interval)
+	}
+
+	// 第一个令牌立即放行, 之后每 50ms 一个
+	start := time.Now()
+	for range 5 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("5 tokens took %v, want about 200ms", elapsed)
+	}
+}
+
+func TestRateCancel(t *testing.T) {
+	l := New(1, 0)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Wait returned after %v", elapsed)
+	}
+}
+
+func TestMaxPerHost(t *testing.T) {
+	l := New(0, 1)
+	release, err := l.Acquire(context.Background(), "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他主机不受影响
+	other, err := l.Acquire(context.Background(), "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other()
+
+	acquired := make(chan func())
+	go func() {
+		r, err := l.Acquire(context.Background(), "10.0.0.1")
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- r
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second Acquire on the same host did not block")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case r := <-acquired:
+		r()
+	case <-time.After(time.Second):
+		t.Fatal("second Acquire not unblocked by release")
+	}
+	if n := l.hostCount(); n != 0 {
+		t.Errorf("%d hosts left after release", n)
+	}
+}
+
+func TestAcquireCancel(t *testing.T) {
+	l := New(0, 1)
+	release, err := l.Acquire(context.Background(), "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := l.Acquire(ctx, "10.0.0.1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want canceled", err)
+	}
+
+	// 取消的等待者不能占着槽位
+	release()
+	if n := l.hostCount(); n != 0 {
+		t.Errorf("%d hosts left after cancel and release", n)
+	}
+	release, err = l.Acquire(context.Background(), "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	// 等令牌时取消也要归还槽位
+	l = New(1, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, "10.0.0.1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if n := l.hostCount(); n != 0 {
+		t.Errorf("%d hosts left after cancel while waiting for a token", n)
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	if l := New(0, 0); l != nil {
+		t.Fatalf("New(0, 0) = %+v, want nil", l)
+	}
+	if l := New(-1, -1); l != nil {
+		t.Fatalf("New(-1, -1) = %+v, want nil", l)
+	}
+
+	var l *Limiter
+	start := time.Now()
+	for range 1000 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		release, err := l.Acquire(context.Background(), "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("nil limiter took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.Acquire(ctx, "10.0.0.1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("nil limiter with canceled ctx: err = %v", err)
+	}
+}
@@ -1,90 +1,292 @@
 package ping
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/duke-git/lancet/v2/formatter"
-	"github.com/duke-git/lancet/v2/netutil"
 	"github.com/duke-git/lancet/v2/slice"
 
 	"github.com/Runninginsilence1/scanner/internal/dumper"
-	"github.com/Runninginsilence1/scanner/internal/ip_gen"
+	"github.com/Runninginsilence1/scanner/internal/limiter"
+	"github.com/Runninginsilence1/scanner/internal/retry"
+	"github.com/Runninginsilence1/scanner/internal/target"
+	"github.com/Runninginsilence1/scanner/internal/timeout"
 	"github.com/Runninginsilence1/scanner/pkg/ip_helper"
 )
 
 // 用来测试ping命令
 
 type Result struct {
-	List  []string `json:"list"`
-	Count int      `json:"count"`
+	List     []string `json:"list"` // 存活的主机
+	Count    int      `json:"count"`
+	FailList []string `json:"fail_list,omitempty"` // 没有应答的主机, 指定 Option.ShowFailed 时输出
+	Hosts    []Host   `json:"hosts"`               // 每台主机的统计, 默认只包含存活的主机
 }
 
-func Single(host string) (ok bool) {
-	ok = netutil.IsPingConnected(host)
-	return
+// Host 是一台主机的 ping 结果
+type Host struct {
+	IP     string `json:"ip"`
+	Alive  bool   `json:"alive"`            // 至少收到了一个应答
+	Method string `json:"method,omitempty"` // 证明主机存活的探测方式, 见 Method* 常量
+	// 统计信息来自最后一种探测方式: ICMP 时每个请求算一次, TCP 时每个端口算一次
+	Stats
+	Ports []int  `json:"ports,omitempty"` // TCP 探测时有响应(连接成功或被拒绝)的端口
+	Error string `json:"error,omitempty"`
 }
 
-func Parallel(prefix, start, end int, format string) {
+// 探测方式
+const (
+	MethodICMP = "icmp" // ICMP echo
+	MethodTCP  = "tcp"  // 连接 Option.Ports 中的端口
+	MethodAuto = "auto" // 先用 ICMP, 没有应答时再用 TCP
+)
+
+// Methods 是所有的探测方式
+var Methods = []string{MethodICMP, MethodTCP, MethodAuto}
+
+// Single 按 opt.Method 探测 host 并统计 RTT 和丢包率;
+// 无法解析主机名或打开 socket 时返回错误, 同时记录在 Host.Error 中
+func Single(ctx context.Context, host string, opt Option) (Host, error) {
+	switch opt.Method {
+	case MethodTCP:
+		return tcpPing(ctx, host, opt), nil
+	case MethodAuto:
+		h, err := icmpPing(ctx, host, opt)
+		if h.Alive || ctx.Err() != nil {
+			return h, err
+		}
+		// ICMP 不可用(例如没有权限)时也使用 TCP
+		return tcpPing(ctx, host, opt), nil
+	default:
+		return icmpPing(ctx, host, opt)
+	}
+}
+
+func icmpPing(ctx context.Context, host string, opt Option) (Host, error) {
+	h := Host{IP: host}
+	release, err := opt.Limiter.Acquire(ctx, host)
+	if err != nil {
+		h.Error = err.Error()
+		return h, err
+	}
+	rtts, err := echo(ctx, host, opt.count(), opt.interval(), opt.timeout())
+	release()
+	if err != nil {
+		h.Error = err.Error()
+		return h, err
+	}
+	h.Stats = newStats(rtts)
+	h.Alive = h.Received > 0
+	if h.Alive {
+		h.Method = MethodICMP
+	}
+	return h, nil
+}
+
+func tcpPing(ctx context.Context, host string, opt Option) Host {
+	h := Host{IP: host}
+	ports := opt.ports()
+	rtts := connect(ctx, host, ports, opt)
+	for i, rtt := range rtts {
+		if rtt > 0 {
+			h.Ports = append(h.Ports, ports[i])
+		}
+	}
+	h.Stats = newStats(rtts)
+	h.Alive = h.Received > 0
+	if h.Alive {
+		h.Method = MethodTCP
+	}
+	return h
+}
+
+type Option struct {
+	MaxWorkers int              // 最大并发数，默认 500
+	Limiter    *limiter.Limiter // 速率和每台主机的并发限制, 为 nil 时不限速, TCP 探测的每个连接都受限制
+	ShowFailed bool             // 是否输出没有应答的主机
+
+	Timeouts *timeout.Config // TCP 探测的连接超时, Timeout 为 0 时使用
+	Retry    *retry.Policy   // TCP 连接超时的重试策略, 为 nil 时不重试
+
+	Method string // 探测方式, 默认 icmp
+	Ports  []int  // TCP 探测的端口, 默认 22, 80, 443
+
+	Count    int           // 每台主机发送的 ICMP 请求数, 默认 4
+	Interval time.Duration // 两个 ICMP 请求之间的间隔, 默认 1s
+	Timeout  time.Duration // 每个 ICMP 请求等待应答的时间, 默认 1s; 指定时也是 TCP 的连接超时
+}
+
+func (opt Option) ports() []int {
+	if len(opt.Ports) > 0 {
+		return opt.Ports
+	}
+	return defaultPorts
+}
+
+func (opt Option) count() int {
+	if opt.Count > 0 {
+		return opt.Count
+	}
+	return defaultCount
+}
+
+func (opt Option) interval() time.Duration {
+	if opt.Interval > 0 {
+		return opt.Interval
+	}
+	return defaultInterval
+}
+
+func (opt Option) timeout() time.Duration {
+	if opt.Timeout > 0 {
+		return opt.Timeout
+	}
+	return defaultTimeout
+}
+
+func Parallel(ctx context.Context, targets *target.List, opt Option, format string) {
 	dumpType, err := dumper.GetType(format)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return
 	}
 
-	var wg sync.WaitGroup
-	taskNum := end - start + 1
-	wg.Add(taskNum)
+	// 设置默认并发数
+	maxWorkers := opt.MaxWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = 500
+	}
 
-	okList := make([]string, 0, 10)
-	failList := make([]string, 0, 10)
+	var (
+		resultChan = make(chan Host, 100)
+		doneChan   = make(chan struct{})
+	)
 
-	for i := start; i <= end; i++ {
-		go func(ip int) {
-			defer wg.Done()
-			ipAddr := ip_gen.GetIp(prefix, ip)
-			//now := time.Now()
-			ok := Single(ipAddr)
-			if ok {
-				okList = append(okList, ipAddr)
+	// 启动结果收集 goroutine, 所有结果只在这里写入
+	var okList, failList []Host
+	go func() {
+		defer close(doneChan)
+		for h := range resultChan {
+			if h.Alive {
+				okList = append(okList, h)
 			} else {
-				failList = append(failList, ipAddr)
+				failList = append(failList, h)
 			}
-		}(i)
+		}
+	}()
+
+	// 只提示第一个错误, 打开 socket 失败(例如没有权限)时每台主机都会失败
+	var errOnce sync.Once
+
+	// 创建任务队列
+	taskCh := make(chan string, 100)
+	var wg sync.WaitGroup
+
+	// 启动 worker pool
+	for w := 0; w < maxWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ipAddr := range taskCh {
+				h, err := Single(ctx, ipAddr, opt)
+				if ctx.Err() != nil {
+					// 被取消时的结果不可信, 不记录
+					return
+				}
+				if err != nil {
+					errOnce.Do(func() {
+						fmt.Fprintln(os.Stderr, err)
+					})
+				}
+				resultChan <- h
+			}
+		}()
 	}
+
+	// 发送任务到任务队列
+	go func() {
+		defer close(taskCh)
+		for t := range targets.All(0) {
+			select {
+			case <-ctx.Done():
+				return
+			case taskCh <- t.Host:
+			}
+		}
+	}()
+
+	// 等待所有 worker 完成
 	wg.Wait()
+	close(resultChan)
+	<-doneChan
 
-	slice.SortBy(okList, func(a, b string) bool {
-		i := ip_helper.GetIpLast(a)
-		j := ip_helper.GetIpLast(b)
-		return i < j
-	})
+	byIP := func(a, b Host) bool {
+		return ip_helper.Less(a.IP, b.IP)
+	}
+	slice.SortBy(okList, byIP)
+	slice.SortBy(failList, byIP)
 
-	output(okList, dumpType)
-	return
+	output(okList, failList, opt, dumpType)
 }
 
-func output(list []string, dumpType dumper.Type) {
+func output(okList, failList []Host, opt Option, dumpType dumper.Type) {
 	switch dumpType {
 	case dumper.Console:
 		{
-			if len(list) != 0 {
+			if len(okList) != 0 {
 				fmt.Println("可用主机:")
-				for _, ip := range list {
-					fmt.Println(ip)
+				for _, h := range okList {
+					fmt.Printf("%s\t%s\t%d/%d 应答, 丢包 %.0f%%, rtt min/avg/max = %.3f/%.3f/%.3f ms",
+						h.IP, h.Method, h.Received, h.Sent, h.Loss, h.MinRTT, h.AvgRTT, h.MaxRTT)
+					if len(h.Ports) > 0 {
+						fmt.Printf(", 端口 %s", formatPorts(h.Ports))
+					}
+					fmt.Println()
 				}
 			} else {
 				fmt.Println("无可用主机")
 			}
+			if opt.ShowFailed && len(failList) != 0 {
+				fmt.Println("不可用主机:")
+				for _, h := range failList {
+					if h.Error != "" {
+						fmt.Printf("%s\t%s\n", h.IP, h.Error)
+					} else {
+						fmt.Println(h.IP)
+					}
+				}
+			}
 		}
 	case dumper.JSON:
 		{
 			r := new(Result)
-			r.List = list
-			r.Count = len(list)
+			r.List = hostIPs(okList)
+			r.Count = len(okList)
+			r.Hosts = okList
+			if opt.ShowFailed {
+				r.FailList = hostIPs(failList)
+				r.Hosts = append(r.Hosts, failList...)
+			}
 			pretty, _ := formatter.Pretty(r)
 			fmt.Println(pretty)
 		}
 	}
 }
+
+func hostIPs(hosts []Host) []string {
+	return slice.Map(hosts, func(_ int, h Host) string {
+		return h.IP
+	})
+}
+
+func formatPorts(ports []int) string {
+	return strings.Join(slice.Map(ports, func(_ int, p int) string {
+		return strconv.Itoa(p)
+	}), ",")
+}
@@ -0,0 +1,135 @@
+package ping
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Runninginsilence1/scanner/internal/limiter"
+	"github.com/Runninginsilence1/scanner/internal/target"
+)
+
+// parallelJSON 运行 Parallel 并解析它输出的 JSON 结果
+func parallelJSON(t *testing.T, ctx context.Context, targets *target.List, opt Option) Result {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	outCh := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		outCh <- out
+	}()
+	Parallel(ctx, targets, opt, "json")
+	w.Close()
+	out := <-outCh
+
+	var result Result
+	if err = json.Unmarshal(out, &result); err != nil {
+		t.Fatalf("decode output %q: %v", out, err)
+	}
+	return result
+}
+
+// startListener 在 127.0.0.1 上监听一个端口并接受所有连接, 返回端口号
+func startListener(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestParallelTCP(t *testing.T) {
+	port := startListener(t)
+	targets, err := target.Parse("127.0.0.0/28")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := Option{
+		MaxWorkers: 4,
+		ShowFailed: true,
+		Method:     MethodTCP,
+		Ports:      []int{port},
+		Timeout:    time.Second,
+	}
+	result := parallelJSON(t, context.Background(), targets, opt)
+
+	// 每台主机必须恰好出现在存活列表或失败列表中的一个
+	var want []string
+	for tg := range targets.All(0) {
+		want = append(want, tg.Host)
+	}
+	got := append(slices.Clone(result.List), result.FailList...)
+	slices.Sort(want)
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("list + fail_list = %v, want %v", got, want)
+	}
+	if result.Count != len(result.List) || len(result.Hosts) != len(want) {
+		t.Errorf("count = %d, hosts = %d, list = %d, want %d hosts", result.Count, len(result.Hosts), len(result.List), len(want))
+	}
+
+	// 127.0.0.1 上有监听, 一定存活
+	if !slices.Contains(result.List, "127.0.0.1") {
+		t.Fatalf("127.0.0.1 not alive: %+v", result)
+	}
+	for _, h := range result.Hosts {
+		if h.IP != "127.0.0.1" {
+			continue
+		}
+		if h.Method != MethodTCP || !slices.Equal(h.Ports, []int{port}) || h.Received != 1 {
+			t.Errorf("127.0.0.1 = %+v, want tcp alive on port %d", h, port)
+		}
+	}
+}
+
+func TestParallelCancel(t *testing.T) {
+	port := startListener(t)
+	targets, err := target.Parse("127.0.0.0/28")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每秒只放行一个连接, 不取消时需要几十秒
+	opt := Option{
+		MaxWorkers: 4,
+		ShowFailed: true,
+		Limiter:    limiter.New(1, 0),
+		Method:     MethodTCP,
+		Ports:      []int{port, port, port},
+		Timeout:    time.Second,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := parallelJSON(t, ctx, targets, opt)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Parallel returned %v after cancel", elapsed)
+	}
+	// 被取消的主机不记录
+	if n := len(result.List) + len(result.FailList); n >= targets.Len(0) {
+		t.Errorf("got %d results after cancel, want fewer than %d", n, targets.Len(0))
+	}
+}
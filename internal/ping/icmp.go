@@ -0,0 +1,214 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// 原生 ICMP echo, 不依赖系统的 ping 命令
+// 优先使用非特权的 ICMP datagram socket(Linux 需要 net.ipv4.ping_group_range 包含当前用户, macOS 默认允许),
+// 不可用时使用需要 root 或 CAP_NET_RAW 的 raw socket
+
+// 未指定时的默认值, 和系统的 ping 命令一样每秒发送一个请求
+const (
+	defaultCount    = 4
+	defaultInterval = time.Second
+	defaultTimeout  = time.Second
+)
+
+// ICMP 协议号, 用于解析收到的报文
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+var echoPayload = []byte("scanner-ping")
+
+// Stats 是一台主机的 ping 统计, RTT 的单位为毫秒
+type Stats struct {
+	Sent     int     `json:"sent"`
+	Received int     `json:"received"`
+	Loss     float64 `json:"loss"` // 丢包率, 百分比
+	MinRTT   float64 `json:"min_rtt_ms,omitempty"`
+	AvgRTT   float64 `json:"avg_rtt_ms,omitempty"`
+	MaxRTT   float64 `json:"max_rtt_ms,omitempty"`
+}
+
+// echoConn 是一个 ICMP socket 和它的发送目标
+type echoConn struct {
+	conn     *icmp.PacketConn
+	dst      net.Addr
+	peer     net.IP
+	ipv6     bool
+	raw      bool // raw socket 会收到所有 ICMP 报文, 需要用 ID 区分; datagram socket 的 ID 由内核分配和过滤
+	id       int
+	protocol int
+}
+
+// listen 为 ip 打开一个 ICMP socket
+func listen(ip *net.IPAddr) (*echoConn, error) {
+	c := &echoConn{peer: ip.IP, id: rand.N(1 << 16)}
+	network, rawNetwork, address := "udp4", "ip4:icmp", "0.0.0.0"
+	c.protocol = protocolICMP
+	if ip.IP.To4() == nil {
+		network, rawNetwork, address = "udp6", "ip6:ipv6-icmp", "::"
+		c.ipv6, c.protocol = true, protocolIPv6ICMP
+	}
+
+	conn, err := icmp.ListenPacket(network, address)
+	if err == nil {
+		c.conn, c.dst = conn, &net.UDPAddr{IP: ip.IP, Zone: ip.Zone}
+		return c, nil
+	}
+	conn, rawErr := icmp.ListenPacket(rawNetwork, address)
+	if rawErr != nil {
+		return nil, fmt.Errorf("open icmp socket: %w (unprivileged: %v)", rawErr, err)
+	}
+	c.conn, c.dst, c.raw = conn, ip, true
+	return c, nil
+}
+
+func (c *echoConn) send(seq int) error {
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if c.ipv6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+	msg := icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{ID: c.id, Seq: seq, Data: echoPayload},
+	}
+	// ICMPv6 的校验和由内核计算
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.WriteTo(b, c.dst)
+	return err
+}
+
+// receive 读取一个发给我们的 echo 应答, 返回它的序号; 其他报文返回 -1
+func (c *echoConn) receive(buf []byte) (int, error) {
+	n, peer, err := c.conn.ReadFrom(buf)
+	if err != nil {
+		return -1, err
+	}
+	var from net.IP
+	switch addr := peer.(type) {
+	case *net.UDPAddr:
+		from = addr.IP
+	case *net.IPAddr:
+		from = addr.IP
+	}
+	if !from.Equal(c.peer) {
+		return -1, nil
+	}
+	msg, err := icmp.ParseMessage(c.protocol, buf[:n])
+	if err != nil {
+		return -1, nil
+	}
+	echo, ok := msg.Body.(*icmp.Echo)
+	if !ok || (msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply) {
+		return -1, nil
+	}
+	if c.raw && echo.ID != c.id {
+		return -1, nil
+	}
+	return echo.Seq, nil
+}
+
+// echo 每隔 interval 发送一个请求, 共 count 个, 每个请求最多等待 timeout;
+// 返回每个请求的 RTT, 没有收到应答的为 0
+func echo(ctx context.Context, host string, count int, interval, timeout time.Duration) ([]time.Duration, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+	c, err := listen(ip)
+	if err != nil {
+		return nil, err
+	}
+	defer c.conn.Close()
+
+	sentAt := make([]time.Time, 0, count)
+	rtts := make([]time.Duration, count)
+	received := 0
+	nextSend := time.Now()
+	buf := make([]byte, 1500)
+	for {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		if len(sentAt) < count && !now.Before(nextSend) {
+			if err = c.send(len(sentAt)); err != nil {
+				return nil, fmt.Errorf("send echo: %w", err)
+			}
+			sentAt = append(sentAt, now)
+			nextSend = now.Add(interval)
+		}
+
+		// 所有请求都已发送, 并且都收到了应答或者超时
+		last := sentAt[len(sentAt)-1].Add(timeout)
+		if len(sentAt) == count && (received == count || !now.Before(last)) {
+			return rtts, nil
+		}
+
+		deadline := last
+		if len(sentAt) < count && nextSend.Before(deadline) {
+			deadline = nextSend
+		}
+		_ = c.conn.SetReadDeadline(deadline)
+		seq, err := c.receive(buf)
+		switch {
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("receive echo: %w", err)
+		case seq < 0 || seq >= len(sentAt) || rtts[seq] != 0:
+			continue
+		}
+		if rtt := time.Since(sentAt[seq]); rtt <= timeout {
+			rtts[seq] = max(rtt, time.Nanosecond)
+			received++
+		}
+	}
+}
+
+// newStats 根据每个请求的 RTT 计算统计信息
+func newStats(rtts []time.Duration) Stats {
+	s := Stats{Sent: len(rtts)}
+	var total, minRTT, maxRTT time.Duration
+	for _, rtt := range rtts {
+		if rtt == 0 {
+			continue
+		}
+		if s.Received == 0 || rtt < minRTT {
+			minRTT = rtt
+		}
+		maxRTT = max(maxRTT, rtt)
+		total += rtt
+		s.Received++
+	}
+	if s.Sent > 0 {
+		s.Loss = float64(s.Sent-s.Received) * 100 / float64(s.Sent)
+	}
+	if s.Received > 0 {
+		s.MinRTT = milliseconds(minRTT)
+		s.AvgRTT = milliseconds(total / time.Duration(s.Received))
+		s.MaxRTT = milliseconds(maxRTT)
+	}
+	return s
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,67 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 用 TCP 连接判断主机是否存活, 适用于丢弃 ICMP 的主机
+// 连接成功或者被拒绝(收到 RST)都说明主机在线, 只有超时和不可达才认为主机不存在
+
+// 未指定端口时连接的端口
+var defaultPorts = []int{22, 80, 443}
+
+// connect 连接 host 的所有端口, 返回每个端口的 RTT, 没有响应的为 0;
+// 每个连接都要经过 opt.Limiter, --max-per-host 为 1 时端口会依次连接
+func connect(ctx context.Context, host string, ports []int, opt Option) []time.Duration {
+	rtts := make([]time.Duration, len(ports))
+
+	var wg sync.WaitGroup
+	for i, p := range ports {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = opt.Retry.Do(ctx, func() error {
+				rtt, err := dial(ctx, host, p, opt)
+				rtts[i] = rtt
+				return err
+			}, func(err error) bool {
+				var netErr net.Error
+				return errors.As(err, &netErr) && netErr.Timeout()
+			})
+		}()
+	}
+	wg.Wait()
+	return rtts
+}
+
+// dial 连接一个端口, 连接成功或被拒绝时返回 RTT
+func dial(ctx context.Context, host string, port int, opt Option) (time.Duration, error) {
+	release, err := opt.Limiter.Acquire(ctx, host)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	timeout := opt.Timeout
+	if timeout <= 0 {
+		timeout = opt.Timeouts.ConnectTimeout(host, defaultTimeout)
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err == nil {
+		_ = conn.Close()
+	}
+	if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+		rtt := time.Since(start)
+		opt.Timeouts.Observe(host, rtt)
+		return max(rtt, time.Nanosecond), nil
+	}
+	return 0, err
+}
@@ -1,35 +1,30 @@
 package port
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
-	"strings"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/duke-git/lancet/v2/formatter"
 	"github.com/duke-git/lancet/v2/slice"
-	"github.com/spf13/cast"
 
-	"github.com/Runninginsilence1/scanner/internal/combiner"
 	"github.com/Runninginsilence1/scanner/internal/dumper"
+	"github.com/Runninginsilence1/scanner/internal/target"
 	"github.com/Runninginsilence1/scanner/pkg/ip_helper"
 )
 
 // 功能: 端口扫描器
 // ip范围复用之前的;
-// 主要是端口范围的裁定, 那指定字符串格式为纯数字或者 x-y 形式的字符串;
+// 端口列表见 ParsePorts 和 TopPorts
 
 var (
-	defaultStartPort = 22
-	defaultEndPort   = 65535
-	defaultTimeout   = time.Second
-)
-
-var (
-	startPort = defaultStartPort
-	endPort   = defaultEndPort
+	defaultTimeout = time.Second
 )
 
 type Result struct {
@@ -37,8 +32,9 @@ type Result struct {
 }
 
 type Port struct {
-	Value int  `json:"value"`
-	Open  bool `json:"open"`
+	Value   int  `json:"value"`
+	Open    bool `json:"open"`
+	Retries int  `json:"retries,omitempty"` // 连接超时后重试的次数
 }
 
 type Host struct {
@@ -46,109 +42,168 @@ type Host struct {
 	Ports []Port `json:"ports"`
 }
 
-func Run(prefix, startHostSuffix, endHostSuffix int, portRange string, opt Option, format string) {
-	var wg sync.WaitGroup
+func Run(ctx context.Context, targets *target.List, ports []int, opt Option, format string) {
 	dumpType, err := dumper.GetType(format)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return
 	}
 
-	err = parseRange(portRange)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return
-	}
+	calTime := time.Now()
+	defer func() {
+		fmt.Printf("扫描完成, 用时: %v ms\n", time.Since(calTime).Milliseconds())
+	}()
 
-	result := Result{}
-	// host + port 双重循环, 只在内部并发
-	portNum := endPort - startPort + 1
-	for i := startHostSuffix; i < endHostSuffix+1; i++ {
-		wg.Add(portNum)
-		host := combiner.CombineHost(prefix, i)
-		hostField := Host{
-			Value: host,
+	// 设置默认并发数
+	maxWorkers := opt.MaxWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = 500
+	}
+	var (
+		resultChan = make(chan hostPort, 100)
+		doneChan   = make(chan struct{})
+	)
+
+	// 启动结果收集 goroutine, 按主机归类所有端口结果
+	var hosts []*Host
+	go func() {
+		defer close(doneChan)
+		index := make(map[string]*Host)
+		for r := range resultChan {
+			h, ok := index[r.host]
+			if !ok {
+				h = &Host{Value: r.host}
+				index[r.host] = h
+				hosts = append(hosts, h)
+			}
+			h.Ports = append(h.Ports, r.port)
 		}
+	}()
 
-		portCh := make(chan Port)
-		portChClose := make(chan bool)
+	// 创建任务队列
+	taskCh := make(chan hostPort, 100)
+	var wg sync.WaitGroup
+
+	// 启动 worker pool
+	for w := 0; w < maxWorkers; w++ {
+		wg.Add(1)
 		go func() {
-			select {
-			case <-portChClose:
-				close(portCh)
-				return
-			case portField := <-portCh:
-				hostField.Ports = append(hostField.Ports, portField)
+			defer wg.Done()
+			for task := range taskCh {
+				task.port.Retries, task.port.Open = detectRetry(ctx, task.host, task.port.Value, opt)
+				if ctx.Err() != nil {
+					// 被取消时的结果不可信, 不记录
+					return
+				}
+				resultChan <- task
 			}
 		}()
+	}
 
-		for j := startPort; j < endPort+1; j++ {
-			go func() {
-				defer wg.Done()
-				open := detect(host, j)
-				portField := Port{
-					Value: j,
-					Open:  open,
+	// 发送任务到任务队列
+	go func() {
+		defer close(taskCh)
+		for t := range targets.All(0) {
+			for _, p := range ports {
+				select {
+				case <-ctx.Done():
+					return
+				case taskCh <- hostPort{host: t.Host, port: Port{Value: p}}:
 				}
-				portCh <- portField
-			}()
+			}
 		}
-		slice.SortBy(hostField.Ports, func(a, b Port) bool {
+	}()
+
+	// 等待所有 worker 完成
+	wg.Wait()
+	close(resultChan)
+	<-doneChan
+
+	result := Result{}
+	for _, h := range hosts {
+		slice.SortBy(h.Ports, func(a, b Port) bool {
 			return a.Value < b.Value
 		})
-
-		result.Hosts = append(result.Hosts, hostField)
-		wg.Done()
+		result.Hosts = append(result.Hosts, *h)
 	}
-
 	slice.SortBy(result.Hosts, func(a, b Host) bool {
-		last1 := ip_helper.GetIpLast(a.Value)
-		last2 := ip_helper.GetIpLast(b.Value)
-		return last1 < last2
+		return ip_helper.Less(a.Value, b.Value)
 	})
 
+	output(result, opt, dumpType)
+}
+
+// hostPort 是一个扫描任务, 同时也用来传递扫描结果
+type hostPort struct {
+	host string
+	port Port
+}
+
+func output(result Result, opt Option, dumpType dumper.Type) {
+	// 默认只输出开放的端口
+	if !opt.ShowClosed {
+		for i := range result.Hosts {
+			result.Hosts[i].Ports = slice.Filter(result.Hosts[i].Ports, func(_ int, p Port) bool {
+				return p.Open
+			})
+		}
+		result.Hosts = slice.Filter(result.Hosts, func(_ int, h Host) bool {
+			return len(h.Ports) > 0
+		})
+	}
+
 	switch dumpType {
+	case dumper.Console:
+		if len(result.Hosts) == 0 {
+			fmt.Println("没有开放端口的主机")
+			return
+		}
+		for _, host := range result.Hosts {
+			fmt.Println(host.Value)
+			for _, p := range host.Ports {
+				state := "open"
+				if !p.Open {
+					state = "closed"
+				}
+				fmt.Printf("  %d/tcp\t%s\n", p.Value, state)
+			}
+		}
 	case dumper.JSON:
 		pretty, _ := formatter.Pretty(result)
 		fmt.Println(pretty)
-	case dumper.Console:
-	default:
-
 	}
+}
 
+// detectRetry 检测端口是否开放, 连接超时(例如 SYN 被丢弃)时按 opt.Retry 重试, 返回重试的次数;
+// 连接被拒绝说明端口关闭, 不重试
+func detectRetry(ctx context.Context, host string, port int, opt Option) (int, bool) {
+	retries, err := opt.Retry.Do(ctx, func() error {
+		return detect(ctx, host, port, opt)
+	}, func(err error) bool {
+		var netErr net.Error
+		return errors.As(err, &netErr) && netErr.Timeout()
+	})
+	return retries, err == nil
 }
 
-func detect(host string, port int) bool {
-	dial, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), defaultTimeout)
+func detect(ctx context.Context, host string, port int, opt Option) error {
+	release, err := opt.Limiter.Acquire(ctx, host)
 	if err != nil {
-		return false
+		return err
 	}
-	defer dial.Close()
-	return true
-}
-
-func parseRange(portRange string) error {
-	split := strings.Split(portRange, "-")
-	if len(split) == 1 {
-		e, err := cast.ToIntE(split[0])
-		if err != nil {
-			return err
-		}
-		startPort = e
-		endPort = 0 // 因为用的 i <= endPort 所以这里要设置为 0
-	} else if len(split) == 2 {
-		e, err := cast.ToIntE(split[0])
-		if err != nil {
-			return err
-		}
-		startPort = e
-		e, err = cast.ToIntE(split[1])
-		if err != nil {
-			return err
-		}
-		endPort = e
-	} else {
-		return fmt.Errorf("invalid port range string, example: %s", "xxx or xxx-yyy")
+	defer release()
+
+	timeouts := opt.Timeouts
+	dialer := net.Dialer{Timeout: timeouts.ConnectTimeout(host, defaultTimeout)}
+	start := time.Now()
+	dial, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+		// 连接成功或者被拒绝都用了一个往返
+		timeouts.Observe(host, time.Since(start))
+	}
+	if err != nil {
+		return err
 	}
+	_ = dial.Close()
 	return nil
 }
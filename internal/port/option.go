@@ -1,9 +1,15 @@
 package port
 
+import (
+	"github.com/Runninginsilence1/scanner/internal/limiter"
+	"github.com/Runninginsilence1/scanner/internal/retry"
+	"github.com/Runninginsilence1/scanner/internal/timeout"
+)
+
 type Option struct {
-	ShowNetwork  bool
-	ShowAuth     bool
-	ShowOk       bool
-	EnablePubKey bool
-	Verbose      bool
+	ShowClosed bool             // 是否输出关闭的端口
+	MaxWorkers int              // 最大并发数，默认 500
+	Limiter    *limiter.Limiter // 连接速率和每台主机的并发限制, 为 nil 时不限速
+	Timeouts   *timeout.Config  // 超时设置, 为 nil 时使用默认值
+	Retry      *retry.Policy    // 连接超时的重试策略, 为 nil 时不重试
 }
@@ -0,0 +1,107 @@
+package port
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/duke-git/lancet/v2/slice"
+)
+
+// topPorts 是最常见的 TCP 端口, 按出现频率从高到低排列 (来自 nmap-services)
+var topPorts = []int{
+	80, 23, 443, 21, 22, 25, 3389, 110, 445, 139,
+	143, 53, 135, 3306, 8080, 1723, 111, 995, 993, 5900,
+	1025, 587, 8888, 199, 1720, 465, 548, 113, 81, 6001,
+	10000, 514, 5060, 179, 1026, 2000, 8443, 8000, 32768, 554,
+	26, 1433, 49152, 2001, 515, 8008, 49154, 1027, 5666, 646,
+	5000, 5631, 631, 49153, 8081, 2049, 88, 79, 5800, 106,
+	2121, 1110, 49155, 6000, 513, 990, 5357, 427, 49156, 543,
+	544, 5101, 144, 7, 389, 8009, 3128, 444, 9999, 5009,
+	7070, 5190, 3000, 5432, 1900, 3986, 13, 1029, 9, 5051,
+	6646, 49157, 1028, 873, 1755, 2717, 4899, 9100, 119, 37,
+}
+
+// MaxTop 是 TopPorts 支持的最大数量
+var MaxTop = len(topPorts)
+
+// TopPorts 返回最常见的 n 个端口
+func TopPorts(n int) ([]int, error) {
+	if n <= 0 || n > MaxTop {
+		return nil, fmt.Errorf("invalid top ports number %d, should be in 1-%d", n, MaxTop)
+	}
+	ports := make([]int, n)
+	copy(ports, topPorts[:n])
+	return ports, nil
+}
+
+// ParsePorts 解析端口列表, 格式为逗号分隔的端口或 x-y 范围, 例如 22,80,443,8000-8100
+func ParsePorts(spec string) ([]int, error) {
+	var ports []int
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		start, end, err := parseRange(field)
+		if err != nil {
+			return nil, err
+		}
+		for p := start; p <= end; p++ {
+			ports = append(ports, p)
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("empty port list, example: %s", "22,80,443,8000-8100")
+	}
+	return ports, nil
+}
+
+// Merge 合并多个端口列表, 去重并排序
+func Merge(lists ...[]int) []int {
+	var ports []int
+	for _, l := range lists {
+		ports = append(ports, l...)
+	}
+	ports = slice.Unique(ports)
+	slice.Sort(ports)
+	return ports
+}
+
+func parseRange(portRange string) (int, int, error) {
+	split := strings.Split(portRange, "-")
+	var start, end int
+	if len(split) == 1 {
+		e, err := parsePort(split[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		start, end = e, e
+	} else if len(split) == 2 {
+		e, err := parsePort(split[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		start = e
+		e, err = parsePort(split[1])
+		if err != nil {
+			return 0, 0, err
+		}
+		end = e
+	} else {
+		return 0, 0, fmt.Errorf("invalid port range string, example: %s", "xxx or xxx-yyy")
+	}
+	if start < 1 || end > 65535 || start > end {
+		return 0, 0, fmt.Errorf("invalid port range %q, ports should be in 1-65535", portRange)
+	}
+	return start, end, nil
+}
+
+// parsePort 只接受十进制, 010 是 10 而不是八进制的 8
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return p, nil
+}
@@ -0,0 +1,81 @@
+package port
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []int
+	}{
+		{"22", []int{22}},
+		{"22,80,443", []int{22, 80, 443}},
+		{"8000-8003", []int{8000, 8001, 8002, 8003}},
+		{"22, 8000 - 8001 ,", []int{22, 8000, 8001}},
+		{"1,65535", []int{1, 65535}},
+		{"443-443", []int{443}},
+		{"010", []int{10}},
+		{"08-09", []int{8, 9}},
+		{"+22", []int{22}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePorts(tt.spec)
+		if err != nil {
+			t.Errorf("ParsePorts(%q): %v", tt.spec, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParsePorts(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParsePortsErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		" , ",
+		"0",
+		"65536",
+		"-22",
+		"22-",
+		"80-22",
+		"1-2-3",
+		"0x16",
+		"1e3",
+		"ssh",
+		"22.5",
+	} {
+		if got, err := ParsePorts(spec); err == nil {
+			t.Errorf("ParsePorts(%q) = %v, want error", spec, got)
+		}
+	}
+}
+
+func TestTopPorts(t *testing.T) {
+	ports, err := TopPorts(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{80, 23, 443}; !slices.Equal(ports, want) {
+		t.Errorf("TopPorts(3) = %v, want %v", ports, want)
+	}
+	// 返回的是副本, 修改它不能影响内置列表
+	ports[0] = 1
+	if again, _ := TopPorts(1); again[0] != 80 {
+		t.Errorf("TopPorts(1) = %v after modifying previous result", again)
+	}
+	for _, n := range []int{0, -1, MaxTop + 1} {
+		if _, err = TopPorts(n); err == nil {
+			t.Errorf("TopPorts(%d) succeeded, want error", n)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	got := Merge([]int{443, 22}, nil, []int{22, 80, 8080})
+	if want := []int{22, 80, 443, 8080}; !slices.Equal(got, want) {
+		t.Errorf("Merge = %v, want %v", got, want)
+	}
+}
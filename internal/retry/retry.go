@@ -0,0 +1,62 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// 所有扫描器共用的重试策略, 只用于丢包、连接重置等暂时性的网络错误
+// 由调用方判断哪些错误可以重试, 认证失败不能重试, 否则可能触发账户锁定
+
+// 每次重试的等待时间最多为 maxBackoff
+const maxBackoff = 30 * time.Second
+
+// Policy 是重试策略, nil 表示不重试
+type Policy struct {
+	Retries int           // 最多重试的次数
+	Backoff time.Duration // 第一次重试前的等待时间, 之后每次翻倍
+}
+
+// New 创建最多重试 retries 次的策略, retries <= 0 时返回 nil(不重试)
+func New(retries int, backoff time.Duration) *Policy {
+	if retries <= 0 {
+		return nil
+	}
+	return &Policy{Retries: retries, Backoff: backoff}
+}
+
+// Do 执行 fn, 返回的错误满足 retryable 时等待一段时间后重试;
+// 返回重试的次数和最后一次的错误, context 被取消时返回 context 的错误
+func (p *Policy) Do(ctx context.Context, fn func() error, retryable func(error) bool) (int, error) {
+	err := fn()
+	if p == nil {
+		return 0, err
+	}
+	retries := 0
+	for ; retries < p.Retries && err != nil && retryable(err); retries++ {
+		timer := time.NewTimer(p.delay(retries))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return retries, ctx.Err()
+		case <-timer.C:
+		}
+		err = fn()
+	}
+	return retries, err
+}
+
+// delay 返回第 n 次(从 0 开始)重试前的等待时间: 在 Backoff * 2^n 的 [1/2, 1] 倍之间随机取值,
+// 避免大量 worker 在同一时刻重试
+func (p *Policy) delay(n int) time.Duration {
+	d := p.Backoff
+	for i := 0; i < n && d < maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
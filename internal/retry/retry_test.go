@@ -0,0 +1,113 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	errTemporary = errors.New("temporary")
+	errFatal     = errors.New("fatal")
+)
+
+func isTemporary(err error) bool {
+	return errors.Is(err, errTemporary)
+}
+
+// failing 返回一个前 n 次调用返回 err、之后成功的函数, 以及调用次数
+func failing(n int, err error) (func() error, *int) {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= n {
+			return err
+		}
+		return nil
+	}, &calls
+}
+
+func TestDelay(t *testing.T) {
+	p := &Policy{Retries: 10, Backoff: 100 * time.Millisecond}
+	tests := []struct {
+		n    int
+		want time.Duration // 抖动之前的等待时间
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{8, 25600 * time.Millisecond},
+		{9, maxBackoff},
+		{100, maxBackoff},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			// 抖动范围是 [1/2, 1] 倍
+			if d := p.delay(tt.n); d < tt.want/2 || d > tt.want {
+				t.Fatalf("delay(%d) = %v, want in [%v, %v]", tt.n, d, tt.want/2, tt.want)
+			}
+		}
+	}
+
+	if d := (&Policy{Retries: 1}).delay(3); d != 0 {
+		t.Errorf("zero backoff delay = %v, want 0", d)
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := New(3, time.Millisecond)
+	tests := []struct {
+		name        string
+		failures    int
+		err         error
+		wantCalls   int
+		wantRetries int
+		wantErr     error
+	}{
+		{"success", 0, errTemporary, 1, 0, nil},
+		{"recovers", 2, errTemporary, 3, 2, nil},
+		{"last retry succeeds", 3, errTemporary, 4, 3, nil},
+		{"gives up", 10, errTemporary, 4, 3, errTemporary},
+		{"not retryable", 10, errFatal, 1, 0, errFatal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, calls := failing(tt.failures, tt.err)
+			retries, err := p.Do(context.Background(), fn, isTemporary)
+			if *calls != tt.wantCalls || retries != tt.wantRetries || !errors.Is(err, tt.wantErr) {
+				t.Errorf("calls = %d, retries = %d, err = %v; want %d, %d, %v",
+					*calls, retries, err, tt.wantCalls, tt.wantRetries, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDoNoRetry(t *testing.T) {
+	if p := New(0, time.Second); p != nil {
+		t.Fatalf("New(0) = %+v, want nil", p)
+	}
+	for _, p := range []*Policy{nil, {}, {Backoff: time.Second}} {
+		fn, calls := failing(10, errTemporary)
+		retries, err := p.Do(context.Background(), fn, isTemporary)
+		if *calls != 1 || retries != 0 || !errors.Is(err, errTemporary) {
+			t.Errorf("policy %+v: calls = %d, retries = %d, err = %v; want exactly one attempt", p, *calls, retries, err)
+		}
+	}
+}
+
+func TestDoCancelDuringSleep(t *testing.T) {
+	p := New(5, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	fn, calls := failing(10, errTemporary)
+	start := time.Now()
+	retries, err := p.Do(ctx, fn, isTemporary)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Do returned after %v", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) || *calls != 1 || retries != 0 {
+		t.Errorf("calls = %d, retries = %d, err = %v; want cancel during first sleep", *calls, retries, err)
+	}
+}
@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Action 是登录成功后在主机上执行的操作, 例如上传文件
+type Action interface {
+	// Name 返回操作名称, 用于输出
+	Name() string
+	// Run 在已登录的 client 上执行操作, host 和 cred 是本次登录使用的地址和凭据
+	Run(ctx context.Context, client *ssh.Client, host string, cred Credential, opt Option) *ActionResult
+}
+
+// ActionResult 是操作的执行结果
+type ActionResult struct {
+	Action  string `json:"action"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"` // 成功时的说明
+	Error   string `json:"error,omitempty"`   // 失败原因
+}
+
+// printAction 输出操作结果
+func printAction(r *ActionResult) {
+	if r == nil {
+		return
+	}
+	if r.OK {
+		fmt.Printf("  %s: ok\t%s\n", r.Action, r.Message)
+	} else {
+		fmt.Printf("  %s: failed\t%s\n", r.Action, r.Error)
+	}
+}
+
+// shellQuote 用单引号包裹参数, 避免被远程 shell 解释
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
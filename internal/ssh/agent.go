@@ -0,0 +1,40 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var ErrNoAgent = errors.New("SSH_AUTH_SOCK is not set")
+
+// AgentSigners 连接 ssh-agent 并返回 agent 提供的所有身份
+// socket 为空时使用 SSH_AUTH_SOCK; 签名需要通过 agent 完成, 所以连接会一直保持到进程退出
+func AgentSigners(socket string) ([]ssh.Signer, error) {
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	if socket == "" {
+		return nil, ErrNoAgent
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("connect ssh-agent: %w", err)
+	}
+
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("list ssh-agent identities: %w", err)
+	}
+	if len(signers) == 0 {
+		conn.Close()
+		return nil, fmt.Errorf("ssh-agent %s has no identities", socket)
+	}
+	return signers, nil
+}
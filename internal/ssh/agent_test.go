@@ -0,0 +1,186 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startAgent 在临时目录的 unix socket 上启动一个 ssh-agent, 返回 socket 路径
+func startAgent(t *testing.T, keyring agent.Agent) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+	return socket
+}
+
+// startServer 启动只接受 authorized 公钥登录的 SSH 服务器, 返回 host:port
+func startServer(t *testing.T, authorized ssh.PublicKey) string {
+	t.Helper()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key %s", ssh.FingerprintSHA256(key))
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for ch := range chans {
+					_ = ch.Reject(ssh.Prohibited, "no channels")
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func newAgentKey(t *testing.T) (agent.AddedKey, ssh.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return agent.AddedKey{PrivateKey: priv, Comment: "test"}, sshPub
+}
+
+func TestAgentSigners(t *testing.T) {
+	keyring := agent.NewKeyring()
+	key, pub := newAgentKey(t)
+	if err := keyring.Add(key); err != nil {
+		t.Fatal(err)
+	}
+	socket := startAgent(t, keyring)
+
+	signers, err := AgentSigners(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signers) != 1 {
+		t.Fatalf("got %d signers, want 1", len(signers))
+	}
+	if !bytes.Equal(signers[0].PublicKey().Marshal(), pub.Marshal()) {
+		t.Errorf("signer key = %s, want %s", ssh.FingerprintSHA256(signers[0].PublicKey()), ssh.FingerprintSHA256(pub))
+	}
+}
+
+func TestAgentSignersEnv(t *testing.T) {
+	keyring := agent.NewKeyring()
+	key, _ := newAgentKey(t)
+	if err := keyring.Add(key); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSH_AUTH_SOCK", startAgent(t, keyring))
+
+	signers, err := AgentSigners("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signers) != 1 {
+		t.Fatalf("got %d signers, want 1", len(signers))
+	}
+}
+
+func TestAgentSignersNoIdentities(t *testing.T) {
+	socket := startAgent(t, agent.NewKeyring())
+
+	_, err := AgentSigners(socket)
+	if err == nil || !strings.Contains(err.Error(), "has no identities") {
+		t.Fatalf("err = %v, want no identities error", err)
+	}
+}
+
+func TestAgentSignersNoAgent(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	if _, err := AgentSigners(""); !errors.Is(err, ErrNoAgent) {
+		t.Fatalf("err = %v, want ErrNoAgent", err)
+	}
+}
+
+func TestAgentLogin(t *testing.T) {
+	keyring := agent.NewKeyring()
+	key, pub := newAgentKey(t)
+	if err := keyring.Add(key); err != nil {
+		t.Fatal(err)
+	}
+	socket := startAgent(t, keyring)
+	addr := startServer(t, pub)
+
+	signers, err := LoadSigners(IdentityOption{UseAgent: true, AgentSocket: socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := Option{Auth: Auth{Methods: []string{MethodPublicKey}, Signers: signers}}
+	info, err := TryConnectServerV2(context.Background(), addr, Credential{User: "root"}, opt)
+	if err != nil {
+		t.Fatalf("login with agent key: %v", err)
+	}
+	if info.Method != MethodPublicKey {
+		t.Errorf("method = %q, want %q", info.Method, MethodPublicKey)
+	}
+
+	// agent 中没有服务器接受的密钥时认证失败
+	_, otherPub := newAgentKey(t)
+	addr = startServer(t, otherPub)
+	if _, err = TryConnectServerV2(context.Background(), addr, Credential{User: "root"}, opt); !errors.Is(err, AuthError) {
+		t.Fatalf("err = %v, want AuthError", err)
+	}
+}
@@ -0,0 +1,168 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/formatter"
+	"github.com/duke-git/lancet/v2/slice"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Runninginsilence1/scanner/internal/dumper"
+	"github.com/Runninginsilence1/scanner/internal/target"
+	"github.com/Runninginsilence1/scanner/pkg/ip_helper"
+)
+
+// 弱算法审计: 只做密钥交换, 不尝试登录
+
+// 拿到主机密钥后用来中断握手的错误
+var errProbeDone = errors.New("probe done")
+
+type AuditResult struct {
+	Hosts []AuditHost `json:"hosts"`
+}
+
+// AuditHost 是单台主机的审计结果
+type AuditHost struct {
+	IP         string            `json:"ip"`
+	Banner     string            `json:"banner"`
+	HostKey    *HostKey          `json:"host_key,omitempty"`
+	Algorithms *ServerAlgorithms `json:"algorithms"`
+	Findings   []Finding         `json:"findings"`
+}
+
+// Probe 只完成密钥交换, 记录服务器的 banner、算法和主机密钥后断开, 不会尝试登录
+func Probe(ctx context.Context, ipPort string, opt Option) (ConnInfo, error) {
+	var info *ConnInfo
+	retries, err := opt.Retry.Do(ctx, func() error {
+		info = &ConnInfo{}
+		config := &ssh.ClientConfig{
+			HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+				info.HostKey = newHostKey(key)
+				return errProbeDone
+			},
+		}
+
+		// HostKeyCallback 总是返回错误, 不会建立连接
+		_, err := dialContext(ctx, ipPort, config, info, opt)
+		return err
+	}, func(err error) bool {
+		return retryable(err, info)
+	})
+	info.Retries = retries
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return ConnInfo{}, err
+	case info.Algorithms == nil:
+		// 没有收到服务器的 KEXINIT, 连接失败或者不是 SSH 服务器;
+		// 收到之后即使密钥交换失败也可以审计
+		return *info, err
+	}
+	return *info, nil
+}
+
+// Audit 检查每台主机提供的算法, 只输出有响应的 SSH 服务器
+func Audit(ctx context.Context, targets *target.List, opt Option, policy *Policy, format string) {
+	dumpType, err := dumper.GetType(format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	calTime := time.Now()
+	defer func() {
+		fmt.Printf("审计完成, 用时: %v ms\n", time.Since(calTime).Milliseconds())
+	}()
+
+	// 设置默认并发数
+	maxWorkers := opt.MaxWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = 500
+	}
+
+	var (
+		resultChan = make(chan AuditHost, 100)
+		doneChan   = make(chan struct{})
+	)
+
+	var hosts []AuditHost
+	go func() {
+		defer close(doneChan)
+		for h := range resultChan {
+			hosts = append(hosts, h)
+		}
+	}()
+
+	taskCh := make(chan target.Target, 100)
+	var wg sync.WaitGroup
+
+	for w := 0; w < maxWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range taskCh {
+				ipAddr := t.Addr(opt.port())
+				info, err := Probe(ctx, ipAddr, opt)
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					return
+				}
+				if err != nil {
+					if opt.Verbose {
+						fmt.Printf("%v\t%v\n", ipAddr, err)
+					}
+					continue
+				}
+				resultChan <- AuditHost{
+					IP:         ipAddr,
+					Banner:     info.Banner,
+					HostKey:    info.HostKey,
+					Algorithms: info.Algorithms,
+					Findings:   policy.Evaluate(info.Algorithms),
+				}
+			}
+		}()
+	}
+
+	go sendTasks(ctx, targets, opt.port(), taskCh)
+
+	wg.Wait()
+	close(resultChan)
+	<-doneChan
+
+	slice.SortBy(hosts, func(a, b AuditHost) bool {
+		return ip_helper.Less(a.IP, b.IP)
+	})
+	auditOutput(hosts, dumpType)
+}
+
+func auditOutput(hosts []AuditHost, dumpType dumper.Type) {
+	switch dumpType {
+	case dumper.Console:
+		weak := 0
+		for _, h := range hosts {
+			fmt.Printf("%v\t%v\n", h.IP, h.Banner)
+			if len(h.Findings) == 0 {
+				fmt.Println("  未发现弱算法")
+				continue
+			}
+			weak++
+			for _, f := range h.Findings {
+				negotiated := ""
+				if f.Negotiated {
+					negotiated = "(已协商)"
+				}
+				fmt.Printf("  [%v]\t%v\t%v%v\t%v\n", f.Severity, f.Category, f.Algorithm, negotiated, f.Reason)
+			}
+		}
+		fmt.Println()
+		fmt.Printf("共审计 %d 台主机, %d 台存在弱算法\n", len(hosts), weak)
+	case dumper.JSON:
+		pretty, _ := formatter.Pretty(AuditResult{Hosts: hosts})
+		fmt.Println(pretty)
+	}
+}
@@ -0,0 +1,108 @@
+package ssh
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 认证方式, 名称和 SSH 协议中的一致
+const (
+	MethodPublicKey           = "publickey"
+	MethodPassword            = "password"
+	MethodKeyboardInteractive = "keyboard-interactive"
+)
+
+var allMethods = []string{MethodPublicKey, MethodPassword, MethodKeyboardInteractive}
+
+// DefaultMethods 是未指定认证方式时使用的认证方式
+// 一些交换机和 NAS 禁用了 password, 只允许 keyboard-interactive
+var DefaultMethods = []string{MethodPassword, MethodKeyboardInteractive}
+
+// Auth 描述一次登录尝试可以使用的认证方式
+type Auth struct {
+	Methods []string     // 按顺序尝试的认证方式
+	Signers []ssh.Signer // publickey 使用的私钥, 由 LoadSigners 读取
+}
+
+// ParseMethods 检查并去重认证方式列表
+func ParseMethods(methods []string) ([]string, error) {
+	var result []string
+	for _, m := range methods {
+		m = strings.ToLower(strings.TrimSpace(m))
+		if !slices.Contains(allMethods, m) {
+			return nil, fmt.Errorf("unknown auth method %q, should be one of %v", m, allMethods)
+		}
+		if !slices.Contains(result, m) {
+			result = append(result, m)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no auth method, should be some of %v", allMethods)
+	}
+	return result, nil
+}
+
+// Has 判断是否启用了某种认证方式
+func (a Auth) Has(method string) bool {
+	return slices.Contains(a.Methods, method)
+}
+
+// usesPassword 判断是否有需要密码的认证方式
+func (a Auth) usesPassword() bool {
+	return slices.ContainsFunc(a.Methods, func(m string) bool {
+		return m != MethodPublicKey
+	})
+}
+
+// without 返回去掉某种认证方式之后的 Auth
+func (a Auth) without(method string) Auth {
+	a.Methods = slices.DeleteFunc(slices.Clone(a.Methods), func(m string) bool {
+		return m == method
+	})
+	return a
+}
+
+// authMethods 生成 ssh.AuthMethod, 每种认证方式被尝试时都会记录到 last 中;
+// 客户端按顺序尝试认证方式, 登录成功时最后一次尝试的就是成功的认证方式
+func (a Auth) authMethods(cred Credential, last *string) []ssh.AuthMethod {
+	methods := make([]ssh.AuthMethod, 0, len(a.Methods))
+	for _, m := range a.Methods {
+		switch m {
+		case MethodPublicKey:
+			if len(a.Signers) == 0 {
+				continue
+			}
+			methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+				*last = MethodPublicKey
+				return a.Signers, nil
+			}))
+		case MethodPassword:
+			methods = append(methods, ssh.PasswordCallback(func() (string, error) {
+				*last = MethodPassword
+				return cred.Password, nil
+			}))
+		case MethodKeyboardInteractive:
+			methods = append(methods, ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				*last = MethodKeyboardInteractive
+				return answerPasswordPrompts(cred.Password, questions, echos), nil
+			}))
+		}
+	}
+	return methods
+}
+
+// answerPasswordPrompts 用密码回答 keyboard-interactive 中的密码提示
+// 不回显的问题或者提示中包含 password 的问题都当作密码提示, 其他问题回答空字符串
+func answerPasswordPrompts(password string, questions []string, echos []bool) []string {
+	answers := make([]string, len(questions))
+	for i, q := range questions {
+		q = strings.ToLower(q)
+		if !echos[i] || strings.Contains(q, "password") || strings.Contains(q, "passcode") || strings.Contains(q, "密码") {
+			answers[i] = password
+		}
+	}
+	return answers
+}
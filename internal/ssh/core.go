@@ -4,142 +4,224 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net"
 	"os"
-	"path/filepath"
-	"strings"
+	"slices"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/duke-git/lancet/v2/fileutil"
 	"github.com/duke-git/lancet/v2/formatter"
 	"github.com/duke-git/lancet/v2/slice"
 	"golang.org/x/crypto/ssh"
 
 	"github.com/Runninginsilence1/scanner/internal/dumper"
-	"github.com/Runninginsilence1/scanner/internal/ip_gen"
+	"github.com/Runninginsilence1/scanner/internal/limiter"
+	"github.com/Runninginsilence1/scanner/internal/retry"
+	"github.com/Runninginsilence1/scanner/internal/target"
+	"github.com/Runninginsilence1/scanner/internal/timeout"
 	"github.com/Runninginsilence1/scanner/pkg/ip_helper"
 )
 
+// 未指定 --connect-timeout/--handshake-timeout 时的默认值
+const (
+	defaultConnectTimeout   = 500 * time.Millisecond // TCP 连接
+	defaultHandshakeTimeout = 10 * time.Second       // SSH 握手和认证
+)
+
 // 错误类型
 var (
 	NetworkError = errors.New("NetworkError")
 	AuthError    = errors.New("AuthError")
 )
 
-// 验证初始化
-var (
-	readPubKeyFileOnce sync.Once
-	authMethod         ssh.AuthMethod
-)
-
 type Result struct {
 	OkList         []string `json:"ok_list"`
 	AuthErrList    []string `json:"auth_err_list"`
 	NetworkErrList []string `json:"network_err_list"`
+	// 端口可以连接但没有完成 SSH 握手的主机, 和网络错误一起由 -n 控制是否输出
+	HandshakeErrList []string `json:"handshake_err_list,omitempty"`
+	// 启用 known_hosts 校验时主机密钥变化的主机
+	HostKeyChangedList []string     `json:"host_key_changed_list,omitempty"`
+	Hosts              []ScanResult `json:"hosts"` // 每台主机的详细结果
 }
 
-func TryConnectServerV2(ctx context.Context, ipPort string, password string, user string, enablePubKey bool) (err error) {
-	method := []ssh.AuthMethod{
-		ssh.Password(password),
-	}
+// ConnInfo 是连接过程中收集到的服务器信息
+type ConnInfo struct {
+	Method     string            // 登录成功时使用的认证方式
+	HostKey    *HostKey          // 服务器的主机密钥, 完成密钥交换后才有, 登录失败时也会记录
+	Banner     string            // 服务器的版本 banner, 例如 SSH-2.0-OpenSSH_9.6
+	Algorithms *ServerAlgorithms // 服务器提供的算法和协商结果
+	Retries    int               // 因为暂时性的网络错误重新连接的次数
+}
 
-	if enablePubKey {
-		method = []ssh.AuthMethod{
-			addIdRsaFileAuth(),
-		}
+// TryConnectServerV2 按 opt.Auth 中的认证方式依次尝试登录, 登录成功后立即断开
+func TryConnectServerV2(ctx context.Context, ipPort string, cred Credential, opt Option) (ConnInfo, error) {
+	client, info, err := Connect(ctx, ipPort, cred, opt)
+	if err != nil {
+		return info, err
 	}
+	client.Close()
+	return info, nil
+}
 
-	// 设置客户端请求参数
-
-	config := &ssh.ClientConfig{
-		User: user,
-		// 支持公钥认证和密码验证
-		Auth: method,
-		// HostKeyCallback: ssh.FixedHostKey(hostKey),
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 忽略主机密钥不匹配的情况
+// Connect 连接并登录 SSH 服务器, 成功时由调用者负责关闭返回的 client
+func Connect(ctx context.Context, ipPort string, cred Credential, opt Option) (*ssh.Client, ConnInfo, error) {
+	var (
+		client *ssh.Client
+		info   *ConnInfo
+	)
+	retries, err := opt.Retry.Do(ctx, func() error {
+		info = &ConnInfo{}
+
+		// 设置客户端请求参数
+
+		config := &ssh.ClientConfig{
+			User: cred.User,
+			// 支持公钥认证和密码验证
+			Auth: opt.Auth.authMethods(cred, &info.Method),
+			// 记录主机密钥, 启用 known_hosts 校验时拒绝密钥变化的主机
+			HostKeyCallback: opt.hostKeyCallback(info),
+			// 优先协商 known_hosts 中记录的密钥类型
+			HostKeyAlgorithms: opt.KnownHosts.hostKeyAlgorithms(ipPort),
+		}
 
-		Timeout: 500 * time.Millisecond, // 这个 timeout 只影响握手阶段的判断，
+		var err error
+		client, err = dialContext(ctx, ipPort, config, info, opt)
+		return err
+	}, func(err error) bool {
+		return retryable(err, info)
+	})
+	info.Retries = retries
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return nil, ConnInfo{}, err
+	case err != nil:
+		info.Method = ""
+		return nil, *info, err
 	}
+	return client, *info, nil
+}
 
-	// 作为客户端连接SSH服务器
-	// 使用 goroutine 和 select 实现 context 取消功能
-
+// dialContext 作为客户端连接SSH服务器
+// 使用 goroutine 和 select 实现 context 取消功能, context 取消时 info 可能仍在被写入, 调用者不能再读取
+func dialContext(ctx context.Context, ipPort string, config *ssh.ClientConfig, info *ConnInfo, opt Option) (*ssh.Client, error) {
 	type dialResult struct {
 		client *ssh.Client
 		err    error
 	}
 	resultCh := make(chan dialResult, 1)
 
+	// 只限制连接和握手阶段, 登录后执行命令时不占用槽位
+	release, err := opt.Limiter.Acquire(ctx, hostOf(ipPort))
+	if err != nil {
+		return nil, err
+	}
 	go func() {
-		client, err := ssh.Dial("tcp", ipPort, config)
+		defer release()
+		client, err := dial(ipPort, config, info, opt)
 		resultCh <- dialResult{client, err}
 	}()
 
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
-	case result := <-resultCh:
-		if result.err != nil {
-			var errOp *net.OpError
-			if errors.As(result.err, &errOp) {
-				err = NetworkError
-			} else {
-				err = AuthError
+		// 等连接结束后再关闭, 避免泄漏
+		go func() {
+			if result := <-resultCh; result.client != nil {
+				result.client.Close()
 			}
-			return err
-		}
-		defer result.client.Close()
-		return nil
+		}()
+		return nil, ctx.Err()
+	case result := <-resultCh:
+		return result.client, result.err
 	}
 }
 
-func addIdRsaFileAuth() ssh.AuthMethod {
-	//C:\Users\H\.ssh\known_hosts
-
-	// 使用 once 保证只读取一次公钥文件, 提高性能
-
-	readPubKeyFileOnce.Do(func() {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			log.Fatal(fmt.Errorf("fetch user home dir: %w", err))
-		}
+// dial 和 ssh.Dial 相同, 另外记录服务器的 banner 和算法, 返回的错误由 classifyError 分类
+// TCP 连接使用连接超时, 握手和认证使用握手超时
+func dial(ipPort string, config *ssh.ClientConfig, info *ConnInfo, opt Option) (*ssh.Client, error) {
+	host := hostOf(ipPort)
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", ipPort, opt.Timeouts.ConnectTimeout(host, defaultConnectTimeout))
+	if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+		// 连接成功或者被拒绝都用了一个往返
+		opt.Timeouts.Observe(host, time.Since(start))
+	}
+	if err != nil {
+		return nil, classifyError(err, info, false)
+	}
 
-		knownHostspath := filepath.Join(homeDir, ".ssh/id_rsa")
+	_ = conn.SetDeadline(time.Now().Add(opt.Timeouts.HandshakeTimeout(host, defaultHandshakeTimeout)))
+	sniff := &sniffConn{Conn: conn}
+	c, chans, reqs, err := ssh.NewClientConn(sniff, ipPort, config)
+	info.Banner, info.Algorithms = sniff.result()
+	if err != nil {
+		return nil, classifyError(err, info, sniff.received())
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
 
-		reader, _, err := fileutil.ReadFile(knownHostspath)
-		if err != nil {
-			log.Fatal(err)
-		}
-		pubKeyBytes, err := io.ReadAll(reader)
+// hostOf 返回 host:port 中的 host
+func hostOf(ipPort string) string {
+	host, _, err := net.SplitHostPort(ipPort)
+	if err != nil {
+		return ipPort
+	}
+	return host
+}
 
-		// 解析id_rsa文件
+type Option struct {
+	ShowNetwork bool
+	ShowAuth    bool
+	ShowOk      bool
+	Auth        Auth        // 认证方式
+	KnownHosts  *KnownHosts // 用来校验主机密钥的 known_hosts, 为 nil 时只记录主机密钥
+
+	WriteKnownHosts string // 扫描结束后把主机密钥写入的 known_hosts 文件
+	Verbose         bool
+	Loop            bool
+	MaxWorkers      int // 最大并发数，默认 500
+	Port            int // SSH 端口，默认 22
+
+	MaxAttempts  int           // 每台主机最多尝试的凭据数, 0 表示不限制
+	AttemptDelay time.Duration // 同一台主机两次尝试之间的间隔
+
+	Timeouts *timeout.Config  // 超时设置, 为 nil 时使用默认值
+	Retry    *retry.Policy    // 网络错误的重试策略, 为 nil 时不重试
+	Limiter  *limiter.Limiter // 连接速率和每台主机的并发限制, 为 nil 时不限速
+
+	Exec   string // 登录成功后执行的命令, 为空时不执行
+	Facts  bool   // 登录成功后收集主机信息
+	Action Action // 登录成功后执行的操作, 由 ssh 的子命令设置
+}
 
-		privateKey, err := ssh.ParsePrivateKey(pubKeyBytes)
-		if err != nil {
-			log.Fatal(err)
-		}
-		// 创建 AuthMethod
-		authMethod = ssh.PublicKeys(privateKey)
-	})
+// commandTimeout 返回在 ipPort 上执行远程命令的超时时间, 也用于收集主机信息和子命令的操作
+func (opt Option) commandTimeout(ipPort string) time.Duration {
+	return opt.Timeouts.CommandTimeout(hostOf(ipPort), defaultExecTimeout)
+}
 
-	return authMethod
+// port 返回实际使用的 SSH 端口
+func (opt Option) port() int {
+	if opt.Port > 0 {
+		return opt.Port
+	}
+	return 22
 }
 
-type Option struct {
-	ShowNetwork  bool
-	ShowAuth     bool
-	ShowOk       bool
-	EnablePubKey bool
-	Verbose      bool
-	Loop         bool
-	MaxWorkers   int // 最大并发数，默认 500
-	Port         int // SSH 端口，默认 22
+// sendTasks 把目标依次发送到任务队列, 发送完毕或 context 取消后关闭队列
+func sendTasks(ctx context.Context, targets *target.List, defaultPort int, taskCh chan<- target.Target) {
+	defer close(taskCh)
+	for t := range targets.All(defaultPort) {
+		select {
+		case <-ctx.Done():
+			return
+		case taskCh <- t:
+		}
+	}
 }
 
-func ScannerV2(ctx context.Context, prefix, start, end int, user, password string, opt Option, format string) {
+func ScannerV2(ctx context.Context, targets *target.List, creds []Credential, opt Option, format string) {
 	dumpType, err := dumper.GetType(format)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -159,51 +241,22 @@ func ScannerV2(ctx context.Context, prefix, start, end int, user, password strin
 
 	// 创建带缓冲的结果 channel
 	var (
-		okChan      = make(chan string, 100)
-		authChan    = make(chan string, 100)
-		networkChan = make(chan string, 100)
-		doneChan    = make(chan struct{})
+		resultChan = make(chan ScanResult, 100)
+		doneChan   = make(chan struct{})
 	)
 
-	var (
-		okArr      []string
-		authArr    []string
-		networkArr []string
-	)
+	var results []ScanResult
 
 	// 启动结果收集 goroutine
 	go func() {
 		defer close(doneChan)
-		for {
-			select {
-			case ip, ok := <-okChan:
-				if !ok {
-					okChan = nil
-				} else {
-					okArr = append(okArr, ip)
-				}
-			case ip, ok := <-authChan:
-				if !ok {
-					authChan = nil
-				} else {
-					authArr = append(authArr, ip)
-				}
-			case ip, ok := <-networkChan:
-				if !ok {
-					networkChan = nil
-				} else {
-					networkArr = append(networkArr, ip)
-				}
-			}
-			// 当所有 channel 都关闭时退出
-			if okChan == nil && authChan == nil && networkChan == nil {
-				return
-			}
+		for r := range resultChan {
+			results = append(results, r)
 		}
 	}()
 
 	// 创建任务队列
-	taskCh := make(chan int, 100)
+	taskCh := make(chan target.Target, 100)
 	var wg sync.WaitGroup
 
 	// 启动 worker pool
@@ -211,7 +264,7 @@ func ScannerV2(ctx context.Context, prefix, start, end int, user, password strin
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for suffix := range taskCh {
+			for t := range taskCh {
 				// 检查 context 是否已取消
 				select {
 				case <-ctx.Done():
@@ -219,138 +272,274 @@ func ScannerV2(ctx context.Context, prefix, start, end int, user, password strin
 				default:
 				}
 
-				var ipAddr string
-				if opt.Port > 0 && opt.Port != 22 {
-					ipAddr = ip_gen.GetSshAddrWithPort(prefix, suffix, opt.Port)
-				} else {
-					ipAddr = ip_gen.GetSshAddr(prefix, suffix)
-				}
+				ipAddr := t.Addr(opt.port())
 				if opt.Loop {
-					loopMode(ctx, ipAddr, password, user, opt)
+					loopMode(ctx, ipAddr, creds, opt)
 					continue
 				}
 
-				err := TryConnectServerV2(ctx, ipAddr, password, user, opt.EnablePubKey)
-				if err == nil {
-					if opt.Verbose {
-						fmt.Printf("%v\tok\n", ipAddr)
-					}
-					okChan <- ipAddr
-				} else if errors.Is(err, AuthError) {
-					if opt.Verbose && opt.ShowAuth {
-						fmt.Printf("%v\tauth error\n", ipAddr)
-					}
-					authChan <- ipAddr
-				} else if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				result, ok := scanHost(ctx, ipAddr, creds, opt)
+				if !ok {
 					// context 取消，不记录错误
 					return
-				} else {
-					if opt.Verbose && opt.ShowNetwork {
-						fmt.Printf("%v\tnetwork error\n", ipAddr)
-					}
-					networkChan <- ipAddr
 				}
+				if opt.Verbose {
+					printResult(result, opt)
+				}
+				resultChan <- result
 			}
 		}()
 	}
 
 	// 发送任务到任务队列
-	go func() {
-		for i := start; i <= end; i++ {
-			select {
-			case <-ctx.Done():
-				break
-			case taskCh <- i:
-			}
-		}
-		close(taskCh)
-	}()
+	go sendTasks(ctx, targets, opt.port(), taskCh)
 
 	// 等待所有 worker 完成
 	wg.Wait()
 
 	// 关闭结果 channel
-	close(okChan)
-	close(authChan)
-	close(networkChan)
+	close(resultChan)
 
 	// 等待结果收集完成
 	<-doneChan
 
-	sortByIpLast(okArr)
-	sortByIpLast(authArr)
-	sortByIpLast(networkArr)
+	output(results, opt, dumpType)
+	saveKnownHosts(results, opt)
+}
+
+// saveKnownHosts 在指定了 opt.WriteKnownHosts 时保存扫描到的主机密钥
+func saveKnownHosts(results []ScanResult, opt Option) {
+	if opt.WriteKnownHosts == "" {
+		return
+	}
+	if err := WriteKnownHosts(opt.WriteKnownHosts, results); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
 
-	output(okArr, authArr, networkArr, opt, dumpType)
+// scanHost 扫描单个主机, context 取消时第二个返回值为 false
+func scanHost(ctx context.Context, ipAddr string, creds []Credential, opt Option) (ScanResult, bool) {
+	client, cred, info, attempts, err := tryCredentials(ctx, ipAddr, creds, opt)
+	result := ScanResult{
+		IP:         ipAddr,
+		Attempts:   attempts,
+		HostKey:    info.HostKey,
+		Banner:     info.Banner,
+		Algorithms: info.Algorithms,
+		Retries:    info.Retries,
+	}
+	switch {
+	case err == nil:
+		result.Status = StatusOK
+		result.Credential = &cred
+		result.Method = info.Method
+		if opt.Facts {
+			result.Facts = gatherFacts(ctx, client, opt.commandTimeout(ipAddr))
+		}
+		if opt.Exec != "" {
+			result.Exec = runCommand(ctx, client, opt.Exec, opt.commandTimeout(ipAddr))
+		}
+		if opt.Action != nil {
+			result.Action = opt.Action.Run(ctx, client, ipAddr, cred, opt)
+		}
+		client.Close()
+	case errors.Is(err, AuthError):
+		result.Status = StatusAuthError
+	case errors.Is(err, HandshakeError):
+		result.Status = StatusHandshakeError
+	case errors.Is(err, ErrHostKeyChanged):
+		result.Status = StatusHostKeyChanged
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return result, false
+	default:
+		result.Status = StatusNetworkError
+	}
+	if err != nil {
+		result.Error = err.Error()
+		var connErr *ConnError
+		if errors.As(err, &connErr) {
+			result.Reason = connErr.Reason
+		}
+	}
+	return result, true
+}
+
+// printResult 实时输出单个结果, 认证失败和网络错误只在对应的开关打开时输出
+func printResult(result ScanResult, opt Option) {
+	switch result.Status {
+	case StatusOK:
+		fmt.Printf("%v\tok\t%v\t%v\t%v%v\n", result.IP, result.Credential, result.Method, result.Banner, hostKeyNote(result))
+		printDetails(result)
+	case StatusAuthError:
+		if opt.ShowAuth {
+			fmt.Printf("%v\tauth error\t%v\t%v%v\n", result.IP, result.Reason, result.Banner, hostKeyNote(result))
+		}
+	case StatusNetworkError:
+		if opt.ShowNetwork {
+			fmt.Printf("%v\tnetwork error\t%v%v\n", result.IP, result.Reason, hostKeyNote(result))
+		}
+	case StatusHandshakeError:
+		if opt.ShowNetwork {
+			fmt.Printf("%v\thandshake error\t%v\t%v%v\n", result.IP, result.Reason, result.Banner, hostKeyNote(result))
+		}
+	case StatusHostKeyChanged:
+		fmt.Printf("%v\thost key changed\t%v\n", result.IP, result.HostKey.Fingerprint)
+	}
+}
+
+// hostKeyNote 返回附加在实时输出后面的 known_hosts 校验结果, 未启用校验或没有拿到主机密钥时为空
+func hostKeyNote(r ScanResult) string {
+	if r.HostKey == nil || r.HostKey.Status == "" {
+		return ""
+	}
+	return "\thost key " + r.HostKey.Status
+}
+
+// printDetails 输出登录成功后收集的主机信息、命令和操作结果
+func printDetails(r ScanResult) {
+	printFacts(r.Facts)
+	printExec(r.Exec)
+	printAction(r.Action)
 }
 
 // 如果是loop模式则忽略 channel 以及 verbose 标志直接显示
 // 成功则退出循环
-func loopMode(ctx context.Context, ipAddr string, password string, user string, opt Option) {
+func loopMode(ctx context.Context, ipAddr string, creds []Credential, opt Option) {
 	for {
-		// 检查 context 是否已取消
+		result, ok := scanHost(ctx, ipAddr, creds, opt)
+		if !ok {
+			// context 取消，退出循环
+			return
+		}
+		printResult(result, opt)
+		if result.Status == StatusOK {
+			return
+		}
+
 		select {
 		case <-ctx.Done():
 			return
-		default:
+		case <-time.After(1 * time.Second):
 		}
+	}
+}
 
-		err := TryConnectServerV2(ctx, ipAddr, password, user, opt.EnablePubKey)
-		if err == nil {
-			fmt.Printf("%v\tok\n", ipAddr)
-			return
-		} else if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-			// context 取消，退出循环
-			return
-		} else if errors.Is(err, AuthError) {
-			if opt.ShowAuth {
-				fmt.Printf("%v\tauth error\n", ipAddr)
-			}
-		} else {
-			if opt.ShowNetwork {
-				fmt.Printf("%v\tnetwork error\n", ipAddr)
-			}
+// sortResults 按 IP 数值排序扫描结果
+func sortResults(results []ScanResult) {
+	slice.SortBy(results, func(a, b ScanResult) bool {
+		return ip_helper.Less(a.IP, b.IP)
+	})
+}
+
+// groupResults 按状态对结果分组
+func groupResults(results []ScanResult) (okArr, authArr, networkArr, handshakeArr, changedArr []ScanResult) {
+	for _, r := range results {
+		switch r.Status {
+		case StatusOK:
+			okArr = append(okArr, r)
+		case StatusAuthError:
+			authArr = append(authArr, r)
+		case StatusNetworkError:
+			networkArr = append(networkArr, r)
+		case StatusHandshakeError:
+			handshakeArr = append(handshakeArr, r)
+		case StatusHostKeyChanged:
+			changedArr = append(changedArr, r)
 		}
-		time.Sleep(1 * time.Second)
 	}
+	return
 }
 
-func sortByIpLast(ips []string) {
-	slice.SortBy(ips, func(a, b string) bool {
-		a = strings.TrimSuffix(a, ":22")
-		b = strings.TrimSuffix(b, ":22")
+// groupHostKeys 按 known_hosts 校验结果分组, 密钥变化的主机见 groupResults;
+// known_hosts 中只有其他类型密钥的主机也算作新主机
+func groupHostKeys(results []ScanResult) (newArr, matchArr []ScanResult) {
+	for _, r := range results {
+		if r.HostKey == nil {
+			continue
+		}
+		switch r.HostKey.Status {
+		case HostKeyNew, HostKeyOtherType:
+			newArr = append(newArr, r)
+		case HostKeyMatch:
+			matchArr = append(matchArr, r)
+		}
+	}
+	return
+}
 
-		i := ip_helper.GetIpLast(a)
-		j := ip_helper.GetIpLast(b)
+// hasHostKey 过滤出拿到了主机密钥的结果
+func hasHostKey(results []ScanResult) []ScanResult {
+	return slice.Filter(results, func(_ int, r ScanResult) bool {
+		return r.HostKey != nil
+	})
+}
 
-		return i < j
+func addrs(results []ScanResult) []string {
+	return slice.Map(results, func(_ int, r ScanResult) string {
+		return r.IP
 	})
 }
 
-func output(okArr, authArr, networkArr []string, opt Option, dumpType dumper.Type) {
+func output(results []ScanResult, opt Option, dumpType dumper.Type) {
+	sortResults(results)
+	okArr, authArr, networkArr, handshakeArr, changedArr := groupResults(results)
+
 	switch dumpType {
 	case dumper.Console:
+		// 主机密钥变化可能意味着主机被冒充, 总是显示
+		if len(changedArr) > 0 {
+			fmt.Println("主机密钥变化:")
+			for _, r := range changedArr {
+				fmt.Printf("%v\t%v %v\n", r.IP, r.HostKey.Type, r.HostKey.Fingerprint)
+			}
+			fmt.Println()
+		}
+		if opt.KnownHosts != nil {
+			newArr, matchArr := groupHostKeys(results)
+			fmt.Println("新主机密钥:")
+			for _, r := range newArr {
+				fmt.Printf("%v\t%v %v", r.IP, r.HostKey.Type, r.HostKey.Fingerprint)
+				if r.HostKey.Status == HostKeyOtherType {
+					fmt.Print("\t(known_hosts 中只有其他类型的密钥)")
+				}
+				fmt.Println()
+			}
+			fmt.Println()
+			fmt.Println("主机密钥一致:")
+			for _, r := range matchArr {
+				fmt.Printf("%v\t%v %v\n", r.IP, r.HostKey.Type, r.HostKey.Fingerprint)
+			}
+			fmt.Println()
+		}
 		if opt.ShowAuth {
 			fmt.Println("认证失败:")
-			for _, ip := range authArr {
-				fmt.Println(ip)
+			for _, r := range authArr {
+				fmt.Printf("%v\t%v\n", r.IP, r.Reason)
 			}
 			fmt.Println()
 		}
 		if opt.ShowNetwork {
 			fmt.Println("网络错误:")
-			for _, ip := range networkArr {
-				fmt.Println(ip)
+			for _, r := range networkArr {
+				fmt.Printf("%v\t%v\n", r.IP, r.Reason)
 			}
 			fmt.Println()
+			if len(handshakeArr) > 0 {
+				fmt.Println("握手失败:")
+				for _, r := range handshakeArr {
+					fmt.Printf("%v\t%v\t%v\n", r.IP, r.Reason, r.Banner)
+				}
+				fmt.Println()
+			}
 		}
 
 		//if opt.ShowOk {
 		if true {
 			if len(okArr) > 0 {
 				fmt.Println("成功登录:")
-				for _, ip := range okArr {
-					fmt.Println(ip)
+				for _, r := range okArr {
+					fmt.Printf("%v\t%v\t%v\n", r.IP, r.Credential, r.Method)
+					printDetails(r)
 				}
 			} else {
 				fmt.Println("没有成功登录的主机")
@@ -359,16 +548,29 @@ func output(okArr, authArr, networkArr []string, opt Option, dumpType dumper.Typ
 	case dumper.JSON:
 		result := Result{}
 
-		result.OkList = okArr
+		result.OkList = addrs(okArr)
+		result.HostKeyChangedList = addrs(changedArr)
+		result.Hosts = slices.Concat(okArr, changedArr)
 
+		// 启用 known_hosts 校验时, 没有打开 -a/-n 也输出拿到了主机密钥的主机, 每台主机的校验结果都在 hosts 中
 		if opt.ShowNetwork {
-			result.NetworkErrList = networkArr
+			result.NetworkErrList = addrs(networkArr)
+			result.HandshakeErrList = addrs(handshakeArr)
+			result.Hosts = append(result.Hosts, networkArr...)
+			result.Hosts = append(result.Hosts, handshakeArr...)
+		} else if opt.KnownHosts != nil {
+			result.Hosts = append(result.Hosts, hasHostKey(networkArr)...)
+			result.Hosts = append(result.Hosts, hasHostKey(handshakeArr)...)
 		}
 
 		if opt.ShowAuth {
-			result.AuthErrList = authArr
+			result.AuthErrList = addrs(authArr)
+			result.Hosts = append(result.Hosts, authArr...)
+		} else if opt.KnownHosts != nil {
+			result.Hosts = append(result.Hosts, hasHostKey(authArr)...)
 		}
 
+		sortResults(result.Hosts)
 		pretty, _ := formatter.Pretty(result)
 		fmt.Println(pretty)
 	}
@@ -0,0 +1,182 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"golang.org/x/crypto/ssh"
+)
+
+// Credential 是一组登录凭据
+type Credential struct {
+	User     string `json:"user"`
+	Password string `json:"password,omitempty"`
+}
+
+func (c Credential) String() string {
+	if c.Password == "" {
+		return c.User
+	}
+	return c.User + ":" + c.Password
+}
+
+// CredentialSource 描述凭据来源, 文件为空时使用单个的 User/Password
+type CredentialSource struct {
+	User          string
+	Password      string
+	UsersFile     string // 用户名列表, 每行一个
+	PasswordsFile string // 密码列表, 每行一个
+	ComboFile     string // user:pass 列表, 每行一个
+}
+
+// LoadCredentials 生成要尝试的凭据列表
+// combo 文件中的凭据排在最前面, 然后是用户名列表和密码列表的笛卡尔积;
+// 只指定了 combo 文件时不再使用 User/Password
+func LoadCredentials(src CredentialSource) ([]Credential, error) {
+	var creds []Credential
+
+	if src.ComboFile != "" {
+		lines, err := readLines(src.ComboFile)
+		if err != nil {
+			return nil, err
+		}
+		for i, line := range lines {
+			user, password, ok := strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("%s: line %d: expected user:pass", src.ComboFile, i+1)
+			}
+			creds = append(creds, Credential{User: user, Password: password})
+		}
+	}
+
+	if src.ComboFile == "" || src.UsersFile != "" || src.PasswordsFile != "" {
+		more, err := product(src)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, more...)
+	}
+	creds = slice.Unique(creds)
+	if len(creds) == 0 {
+		return nil, errors.New("no credentials to try")
+	}
+	return creds, nil
+}
+
+// product 返回用户名列表和密码列表的笛卡尔积
+func product(src CredentialSource) ([]Credential, error) {
+	users := []string{src.User}
+	if src.UsersFile != "" {
+		lines, err := readLines(src.UsersFile)
+		if err != nil {
+			return nil, err
+		}
+		users = lines
+	}
+	passwords := []string{src.Password}
+	if src.PasswordsFile != "" {
+		lines, err := readLines(src.PasswordsFile)
+		if err != nil {
+			return nil, err
+		}
+		passwords = lines
+	}
+	var creds []Credential
+	for _, user := range users {
+		for _, password := range passwords {
+			creds = append(creds, Credential{User: user, Password: password})
+		}
+	}
+	return creds, nil
+}
+
+// readLines 读取文件中的非空行
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// tryCredentials 依次尝试凭据, 遇到第一个成功的凭据就停止, 成功时由调用者负责关闭返回的 client
+// 只有认证失败才会继续尝试下一个凭据, 网络错误直接返回;
+// 每台主机最多尝试 opt.MaxAttempts 次, 两次尝试之间间隔 opt.AttemptDelay
+func tryCredentials(ctx context.Context, ipAddr string, creds []Credential, opt Option) (client *ssh.Client, cred Credential, info ConnInfo, attempts int, err error) {
+	auth := opt.Auth
+	if !auth.usesPassword() {
+		// 只有公钥登录时不使用密码, 每个用户名只需要尝试一次
+		creds = slice.UniqueBy(creds, func(c Credential) string {
+			return c.User
+		})
+		creds = slice.Map(creds, func(_ int, c Credential) Credential {
+			return Credential{User: c.User}
+		})
+	}
+
+	// 同一个用户名的公钥只需要尝试一次
+	keyTried := make(map[string]bool)
+	// 所有凭据的重连次数之和
+	retries := 0
+
+	err = AuthError
+	for i, c := range creds {
+		if opt.MaxAttempts > 0 && i >= opt.MaxAttempts {
+			break
+		}
+		if i > 0 && opt.AttemptDelay > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, Credential{}, info, attempts, ctx.Err()
+			case <-time.After(opt.AttemptDelay):
+			}
+		}
+
+		attemptOpt := opt
+		if auth.Has(MethodPublicKey) {
+			if keyTried[c.User] {
+				attemptOpt.Auth = auth.without(MethodPublicKey)
+			}
+			keyTried[c.User] = true
+		}
+
+		attempts++
+		var attemptInfo ConnInfo
+		client, attemptInfo, err = Connect(ctx, ipAddr, c, attemptOpt)
+		retries += attemptInfo.Retries
+		if attemptInfo.HostKey != nil || attemptInfo.Banner != "" {
+			info = attemptInfo
+		}
+		info.Retries = retries
+		if err == nil {
+			if info.Method == MethodPublicKey {
+				c.Password = ""
+			}
+			return client, c, info, attempts, nil
+		}
+		if !errors.Is(err, AuthError) {
+			return nil, Credential{}, info, attempts, err
+		}
+	}
+	return nil, Credential{}, info, attempts, err
+}
@@ -0,0 +1,98 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeFile 在临时目录中写入一个文件, 返回它的路径
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCredentials(t *testing.T) {
+	users := writeFile(t, "users", "root\nadmin\r\n\nroot\n")
+	passwords := writeFile(t, "passwords", "123456\n pass with spaces \n")
+	combo := writeFile(t, "combo", "pi:raspberry\r\nroot:a:b:c\nguest:\n")
+
+	tests := []struct {
+		name string
+		src  CredentialSource
+		want []Credential
+	}{
+		{
+			name: "single",
+			src:  CredentialSource{User: "root", Password: "secret"},
+			want: []Credential{{"root", "secret"}},
+		},
+		{
+			name: "users file with single password",
+			src:  CredentialSource{Password: "secret", UsersFile: users},
+			want: []Credential{{"root", "secret"}, {"admin", "secret"}},
+		},
+		{
+			name: "single user with passwords file",
+			src:  CredentialSource{User: "root", PasswordsFile: passwords},
+			want: []Credential{{"root", "123456"}, {"root", " pass with spaces "}},
+		},
+		{
+			name: "cartesian product",
+			src:  CredentialSource{UsersFile: users, PasswordsFile: passwords},
+			want: []Credential{
+				{"root", "123456"}, {"root", " pass with spaces "},
+				{"admin", "123456"}, {"admin", " pass with spaces "},
+			},
+		},
+		{
+			name: "combo only ignores user and password",
+			src:  CredentialSource{User: "root", Password: "secret", ComboFile: combo},
+			want: []Credential{{"pi", "raspberry"}, {"root", "a:b:c"}, {"guest", ""}},
+		},
+		{
+			name: "combo first then product",
+			src:  CredentialSource{Password: "secret", UsersFile: users, ComboFile: combo},
+			want: []Credential{
+				{"pi", "raspberry"}, {"root", "a:b:c"}, {"guest", ""},
+				{"root", "secret"}, {"admin", "secret"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadCredentials(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCredentialsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  CredentialSource
+	}{
+		{"missing users file", CredentialSource{UsersFile: filepath.Join(t.TempDir(), "missing")}},
+		{"missing passwords file", CredentialSource{User: "root", PasswordsFile: filepath.Join(t.TempDir(), "missing")}},
+		{"combo line without colon", CredentialSource{ComboFile: writeFile(t, "combo", "root:toor\nadmin\n")}},
+		{"empty combo file", CredentialSource{ComboFile: writeFile(t, "combo", "\n\n")}},
+		{"empty users file", CredentialSource{Password: "secret", UsersFile: writeFile(t, "users", "")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := LoadCredentials(tt.src); err == nil {
+				t.Errorf("got %v, want error", got)
+			}
+		})
+	}
+}
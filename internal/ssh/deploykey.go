@@ -0,0 +1,142 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 把公钥追加到成功登录的用户的 ~/.ssh/authorized_keys, 然后用对应的私钥重新登录验证
+
+// deployKeyScript 追加公钥, 已经有相同公钥(忽略注释和选项)时不修改文件;
+// 参数 $1 是密钥类型, $2 是 base64 编码的公钥, $3 是要追加的完整行.
+// 只比较非注释行中第一个密钥类型字段和它后面的公钥字段, 被注释掉的公钥不算已存在
+const deployKeyScript = `set -e
+umask 077
+mkdir -p ~/.ssh
+chmod 700 ~/.ssh
+f=~/.ssh/authorized_keys
+touch "$f"
+chmod 600 "$f"
+if awk -v t="$1" -v k="$2" '
+	/^[[:space:]]*#/ { next }
+	{
+		for (i = 1; i < NF; i++) if ($i ~ /^(ssh-|ecdsa-|sk-)/) {
+			if ($i == t && $(i + 1) == k) found = 1
+			break
+		}
+	}
+	END { exit !found }' "$f"; then
+	echo present
+	exit 0
+fi
+if [ -s "$f" ] && [ -n "$(tail -c 1 "$f")" ]; then echo >> "$f"; fi
+printf '%s\n' "$3" >> "$f"
+echo added`
+
+// DeployKeyAction 把公钥安装到每台成功登录的主机
+type DeployKeyAction struct {
+	line    string // authorized_keys 中的一行
+	key     ssh.PublicKey
+	signers []ssh.Signer // 和公钥对应的私钥, 用于验证, 为空时不验证
+}
+
+// NewDeployKeyAction 读取公钥文件, 并查找对应的私钥用于登录验证:
+// 先尝试去掉 .pub 后缀的私钥文件, 然后是 ssh-agent 中的身份, agent 不可用时忽略
+func NewDeployKeyAction(pubPath string, passphraseEnv string, agentSocket string) (*DeployKeyAction, error) {
+	data, err := os.ReadFile(pubPath)
+	if err != nil {
+		return nil, err
+	}
+	key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pubPath, err)
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return nil, fmt.Errorf("%s: only one public key is supported", pubPath)
+	}
+
+	a := &DeployKeyAction{
+		line: strings.TrimSpace(string(data)),
+		key:  key,
+	}
+
+	privPath := strings.TrimSuffix(pubPath, ".pub")
+	if privPath != pubPath {
+		if _, err = os.Stat(privPath); err == nil {
+			signer, err := loadSigner(privPath, passphraseEnv)
+			if err != nil {
+				return nil, fmt.Errorf("load private key %s: %w", privPath, err)
+			}
+			a.signers = append(a.signers, signer)
+		}
+	}
+	if len(a.signers) == 0 {
+		agentSigners, _ := AgentSigners(agentSocket)
+		for _, s := range agentSigners {
+			if bytes.Equal(s.PublicKey().Marshal(), key.Marshal()) {
+				a.signers = append(a.signers, s)
+			}
+		}
+	}
+	if len(a.signers) > 0 && !bytes.Equal(a.signers[0].PublicKey().Marshal(), key.Marshal()) {
+		return nil, fmt.Errorf("private key %s does not match %s", privPath, pubPath)
+	}
+	return a, nil
+}
+
+// CanVerify 判断是否找到了用于验证的私钥
+func (a *DeployKeyAction) CanVerify() bool {
+	return len(a.signers) > 0
+}
+
+func (a *DeployKeyAction) Name() string {
+	return "deploy-key"
+}
+
+func (a *DeployKeyAction) Run(ctx context.Context, client *ssh.Client, host string, cred Credential, opt Option) *ActionResult {
+	result := &ActionResult{Action: a.Name()}
+
+	blob := base64.StdEncoding.EncodeToString(a.key.Marshal())
+	command := fmt.Sprintf("sh -c %s sh %s %s %s", shellQuote(deployKeyScript), shellQuote(a.key.Type()), shellQuote(blob), shellQuote(a.line))
+	r := runCommand(ctx, client, command, opt.commandTimeout(host))
+	state := strings.TrimSpace(r.Stdout)
+	if r.ExitCode != 0 || (state != "added" && state != "present") {
+		result.Error = fmt.Sprintf("install key: exit %d: %s", r.ExitCode, strings.TrimSpace(r.Error+" "+r.Stderr))
+		return result
+	}
+	if state == "added" {
+		result.Message = "已添加"
+	} else {
+		result.Message = "已存在"
+	}
+
+	if !a.CanVerify() {
+		result.OK = true
+		result.Message += ", 没有对应的私钥, 未验证"
+		return result
+	}
+	if err := a.verify(ctx, host, cred.User, opt); err != nil {
+		result.Error = fmt.Sprintf("key %s but login with key failed: %v", state, err)
+		return result
+	}
+	result.OK = true
+	result.Message += ", 公钥登录验证成功"
+	return result
+}
+
+// verify 只用公钥重新登录一次
+func (a *DeployKeyAction) verify(ctx context.Context, host, user string, opt Option) error {
+	opt.Auth = Auth{Methods: []string{MethodPublicKey}, Signers: a.signers}
+	_, err := TryConnectServerV2(ctx, host, Credential{User: user}, opt)
+	if errors.Is(err, AuthError) {
+		return errors.New("key rejected")
+	}
+	return err
+}
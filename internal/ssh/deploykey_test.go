@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// shellServer 用本地的 sh 执行命令, HOME 指向 home
+func shellServer(home string) func(ch ssh.Channel, command string) {
+	return func(ch ssh.Channel, command string) {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		cmd.Stdout = ch
+		cmd.Stderr = ch.Stderr()
+		code := 0
+		var exitErr *exec.ExitError
+		if err := cmd.Run(); errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		} else if err != nil {
+			code = 127
+		}
+		exitStatus(ch, uint32(code))
+	}
+}
+
+func TestDeployKeyPresent(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	_, pub := newAgentKey(t)
+	key := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	_, otherPub := newAgentKey(t)
+	other := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(otherPub)))
+
+	tests := []struct {
+		name     string
+		existing string
+		want     string
+	}{
+		{"empty", "", "已添加"},
+		{"other key", other + " other\n", "已添加"},
+		{"same key", key + " old comment\n", "已存在"},
+		{"with options", `restrict,command="uptime" ` + key + "\n", "已存在"},
+		{"commented out", "# " + key + "\n", "已添加"},
+		{"indented comment", "  #" + key + "\n", "已添加"},
+		{"key in another key's comment", other + " " + key + "\n", "已添加"},
+		{"blob only in option", `command="echo ` + key[len(pub.Type())+1:] + `" ` + other + "\n", "已添加"},
+		{"no trailing newline", other, "已添加"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			f := filepath.Join(home, ".ssh", "authorized_keys")
+			if err := os.MkdirAll(filepath.Dir(f), 0o700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(f, []byte(tt.existing), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			client := startExecServer(t, shellServer(home))
+			a := &DeployKeyAction{line: key + " new", key: pub}
+			r := a.Run(context.Background(), client, "127.0.0.1", Credential{}, Option{})
+			if !r.OK || !strings.HasPrefix(r.Message, tt.want) {
+				t.Fatalf("result = %+v, want %s", r, tt.want)
+			}
+
+			data, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.existing
+			if tt.want == "已添加" {
+				if want != "" && !strings.HasSuffix(want, "\n") {
+					want += "\n"
+				}
+				want += key + " new\n"
+			}
+			if string(data) != want {
+				t.Errorf("authorized_keys = %q, want %q", data, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package ssh
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// 连接失败的详细原因, 记录在 ScanResult.Reason 中
+const (
+	// 网络错误, 归类为 NetworkError
+	ReasonRefused     = "connection_refused" // 端口没有监听
+	ReasonTimeout     = "timeout"            // 连接或握手超时, 通常是被防火墙丢弃
+	ReasonUnreachable = "host_unreachable"   // 主机或网络不可达
+	ReasonNetwork     = "network"            // 其他网络错误, 例如连接被重置或在握手前被关闭
+
+	// 握手错误, 归类为 HandshakeError
+	ReasonNotSSH    = "not_ssh"          // 端口上不是 SSH 服务, 没有收到 SSH banner
+	ReasonHandshake = "handshake_failed" // 收到了 SSH banner, 但密钥交换失败, 例如没有共同的算法
+
+	// 认证错误, 归类为 AuthError
+	ReasonAuthRejected        = "auth_rejected"          // 凭据被拒绝
+	ReasonTooManyAuthFailures = "too_many_auth_failures" // 服务器因为认证失败次数过多断开连接
+	ReasonMethodNotAllowed    = "method_not_allowed"     // 服务器不允许使用任何一种我们提供的认证方式
+
+	ReasonHostKeyChanged = "host_key_changed" // 归类为 ErrHostKeyChanged
+)
+
+// HandshakeError 表示端口可以连接, 但没有完成 SSH 握手
+var HandshakeError = errors.New("HandshakeError")
+
+// ConnError 是连接失败的详细原因
+// errors.Is 可以用 NetworkError, HandshakeError, AuthError 或 ErrHostKeyChanged 判断错误的大类
+type ConnError struct {
+	Reason string
+	Kind   error
+	Err    error
+}
+
+func (e *ConnError) Error() string {
+	return e.Reason + ": " + e.Err.Error()
+}
+
+func (e *ConnError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *ConnError) Unwrap() error {
+	return e.Err
+}
+
+// classifyError 根据错误和握手进度判断连接失败的原因
+// received 表示是否收到了服务器的数据; info.HostKey 不为空表示已经完成密钥交换, 进入了认证阶段
+func classifyError(err error, info *ConnInfo, received bool) error {
+	reason, kind := ReasonAuthRejected, AuthError
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrHostKeyChanged):
+		reason, kind = ReasonHostKeyChanged, ErrHostKeyChanged
+	case info.HostKey == nil && info.Banner == "" && received:
+		// 收到了数据但不是 SSH banner, 例如 SMTP/FTP 发送问候后等待命令直到超时, 不能归为网络超时
+		reason, kind = ReasonNotSSH, HandshakeError
+	case errors.Is(err, syscall.ECONNREFUSED):
+		reason, kind = ReasonRefused, NetworkError
+	case errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH):
+		reason, kind = ReasonUnreachable, NetworkError
+	case errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		reason, kind = ReasonTimeout, NetworkError
+	case errors.As(err, new(*net.OpError)) || errors.Is(err, io.EOF):
+		reason, kind = ReasonNetwork, NetworkError
+	case info.HostKey == nil:
+		reason, kind = ReasonHandshake, HandshakeError
+	case strings.Contains(strings.ToLower(err.Error()), "too many authentication failures"):
+		reason = ReasonTooManyAuthFailures
+	case info.Method == "":
+		// 没有任何一种认证方式被服务器接受尝试
+		reason = ReasonMethodNotAllowed
+	}
+	return &ConnError{Reason: reason, Kind: kind, Err: err}
+}
+
+// retryable 判断连接失败后是否可以重试: 只重试超时和连接被重置等暂时性的网络错误,
+// 并且还没有完成密钥交换, 即没有发送过任何凭据, 避免重试认证导致账户被锁定;
+// not_ssh 等握手错误重试也不会有不同的结果
+func retryable(err error, info *ConnInfo) bool {
+	var connErr *ConnError
+	if !errors.As(err, &connErr) || info.HostKey != nil {
+		return false
+	}
+	return connErr.Reason == ReasonTimeout || connErr.Reason == ReasonNetwork
+}
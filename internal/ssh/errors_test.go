@@ -0,0 +1,98 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	hostKey := &HostKey{Type: "ssh-ed25519"}
+	dialErr := func(errno syscall.Errno) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)}
+	}
+	timeoutErr := &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+
+	tests := []struct {
+		name     string
+		err      error
+		info     ConnInfo
+		received bool
+		reason   string
+		kind     error
+	}{
+		{"refused", dialErr(syscall.ECONNREFUSED), ConnInfo{}, false, ReasonRefused, NetworkError},
+		{"host unreachable", dialErr(syscall.EHOSTUNREACH), ConnInfo{}, false, ReasonUnreachable, NetworkError},
+		{"network unreachable", dialErr(syscall.ENETUNREACH), ConnInfo{}, false, ReasonUnreachable, NetworkError},
+		{"dial timeout", &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}, ConnInfo{}, false, ReasonTimeout, NetworkError},
+		{"handshake timeout before any data", timeoutErr, ConnInfo{}, false, ReasonTimeout, NetworkError},
+		{"timeout after ssh banner", timeoutErr, ConnInfo{Banner: "SSH-2.0-OpenSSH_9.6"}, true, ReasonTimeout, NetworkError},
+		{"timeout after key exchange", timeoutErr, ConnInfo{Banner: "SSH-2.0-OpenSSH_9.6", HostKey: hostKey}, true, ReasonTimeout, NetworkError},
+		// SMTP/FTP 发送问候后等待命令, 最后超时; 收到了数据但没有 SSH banner
+		{"not ssh greeting then timeout", timeoutErr, ConnInfo{}, true, ReasonNotSSH, HandshakeError},
+		{"not ssh greeting then eof", io.EOF, ConnInfo{}, true, ReasonNotSSH, HandshakeError},
+		{"reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, ConnInfo{}, false, ReasonNetwork, NetworkError},
+		{"closed before banner", io.EOF, ConnInfo{}, false, ReasonNetwork, NetworkError},
+		{"no common algorithm", errors.New("ssh: handshake failed: ssh: no common algorithm for key exchange"), ConnInfo{Banner: "SSH-2.0-OpenSSH_9.6"}, true, ReasonHandshake, HandshakeError},
+		{"method not allowed", errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none], no supported methods remain"), ConnInfo{Banner: "SSH-2.0-OpenSSH_9.6", HostKey: hostKey}, true, ReasonMethodNotAllowed, AuthError},
+		{"too many auth failures", errors.New("ssh: disconnect, reason 2: Too many authentication failures"), ConnInfo{Banner: "SSH-2.0-OpenSSH_9.6", HostKey: hostKey, Method: MethodPublicKey}, true, ReasonTooManyAuthFailures, AuthError},
+		{"auth rejected", errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password], no supported methods remain"), ConnInfo{Banner: "SSH-2.0-OpenSSH_9.6", HostKey: hostKey, Method: MethodPassword}, true, ReasonAuthRejected, AuthError},
+		{"host key changed", fmt.Errorf("ssh: handshake failed: %w", ErrHostKeyChanged), ConnInfo{Banner: "SSH-2.0-OpenSSH_9.6", HostKey: hostKey}, true, ReasonHostKeyChanged, ErrHostKeyChanged},
+		// 主机密钥校验在没有 banner 的情况下也优先于其他原因
+		{"host key changed wins", fmt.Errorf("%w: %w", ErrHostKeyChanged, io.EOF), ConnInfo{}, true, ReasonHostKeyChanged, ErrHostKeyChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyError(tt.err, &tt.info, tt.received)
+			var connErr *ConnError
+			if !errors.As(err, &connErr) {
+				t.Fatalf("classifyError returned %T", err)
+			}
+			if connErr.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q", connErr.Reason, tt.reason)
+			}
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("kind = %v, want %v", connErr.Kind, tt.kind)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("original error not wrapped: %v", err)
+			}
+
+			want := (tt.reason == ReasonTimeout || tt.reason == ReasonNetwork) && tt.info.HostKey == nil
+			if got := retryable(err, &tt.info); got != want {
+				t.Errorf("retryable = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// 完成密钥交换后已经发送过凭据, 任何错误都不重试
+func TestRetryableAfterKeyExchange(t *testing.T) {
+	info := &ConnInfo{Banner: "SSH-2.0-OpenSSH_9.6", HostKey: &HostKey{Type: "ssh-ed25519"}}
+	for _, reason := range []string{
+		ReasonRefused, ReasonTimeout, ReasonUnreachable, ReasonNetwork,
+		ReasonNotSSH, ReasonHandshake,
+		ReasonAuthRejected, ReasonTooManyAuthFailures, ReasonMethodNotAllowed,
+		ReasonHostKeyChanged,
+	} {
+		err := &ConnError{Reason: reason, Kind: NetworkError, Err: io.EOF}
+		if retryable(err, info) {
+			t.Errorf("%s retryable after key exchange", reason)
+		}
+	}
+
+	if retryable(io.EOF, &ConnInfo{}) {
+		t.Error("unclassified error retryable")
+	}
+}
+
+// timeoutError 是 Dialer 超时时返回的错误类型
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
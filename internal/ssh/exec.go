@@ -0,0 +1,147 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 登录成功后执行的远程命令
+
+// 默认的命令超时时间
+const defaultExecTimeout = 10 * time.Second
+
+// stdout 和 stderr 最多保留的字节数, 超出部分丢弃
+const maxOutputBytes = 64 * 1024
+
+// ExecResult 是远程命令的执行结果
+type ExecResult struct {
+	Command  string `json:"command"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`       // 没有拿到退出码时为 -1
+	Error    string `json:"error,omitempty"` // 无法执行、超时或者被信号终止
+}
+
+// runCommand 在新的 session 中执行命令, 超时或者 context 取消时终止命令
+func runCommand(ctx context.Context, client *ssh.Client, command string, timeout time.Duration) *ExecResult {
+	return runCommandInput(ctx, client, command, nil, timeout)
+}
+
+// runCommandInput 和 runCommand 相同, stdin 不为 nil 时作为命令的标准输入
+// 密码等敏感数据通过标准输入传递, 避免出现在远程主机的进程列表中
+func runCommandInput(ctx context.Context, client *ssh.Client, command string, stdin []byte, timeout time.Duration) *ExecResult {
+	result := &ExecResult{Command: command, ExitCode: -1}
+
+	session, err := client.NewSession()
+	if err != nil {
+		result.Error = fmt.Sprintf("new session: %v", err)
+		return result
+	}
+	defer session.Close()
+
+	var stdout, stderr cappedBuffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
+	}
+	if err = session.Start(command); err != nil {
+		result.Error = fmt.Sprintf("start command: %v", err)
+		return result
+	}
+
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- session.Wait()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	finished := false
+	select {
+	case err = <-waitCh:
+		finished = true
+	case <-timer.C:
+		err = fmt.Errorf("command timed out after %v", timeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if !finished {
+		// 超时或取消时命令还在运行, 先发送信号再关闭 session
+		_ = session.Signal(ssh.SIGKILL)
+		session.Close()
+	}
+
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+		result.ExitCode = 0
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitStatus()
+		if exitErr.Signal() != "" {
+			result.Error = fmt.Sprintf("killed by signal %s", exitErr.Signal())
+		}
+	default:
+		result.Error = err.Error()
+	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	return result
+}
+
+// cappedBuffer 只保留前 maxOutputBytes 个字节
+// 超时或取消时 session 的复制 goroutine 可能还在写入, 读写都需要加锁
+type cappedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if remain := maxOutputBytes - b.buf.Len(); remain < len(p) {
+		b.truncated = true
+		b.buf.Write(p[:max(remain, 0)])
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return b.buf.String() + "\n...(truncated)"
+	}
+	return b.buf.String()
+}
+
+// printExec 输出命令结果, 每行缩进两个空格
+func printExec(r *ExecResult) {
+	if r == nil {
+		return
+	}
+	fmt.Printf("  $ %s\t(exit %d)\n", r.Command, r.ExitCode)
+	if r.Error != "" {
+		fmt.Printf("  ! %s\n", r.Error)
+	}
+	for _, out := range []string{r.Stdout, r.Stderr} {
+		out = strings.TrimRight(out, "\n")
+		if out == "" {
+			continue
+		}
+		for _, line := range strings.Split(out, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startExecServer 启动不需要认证的 SSH 服务器, 每个 exec 请求交给 handler 处理, 返回已连接的 client
+func startExecServer(t *testing.T, handler func(ch ssh.Channel, command string)) *ssh.Client {
+	t.Helper()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveExec(conn, config, handler)
+		}
+	}()
+
+	client, err := ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "root",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func serveExec(conn net.Conn, config *ssh.ServerConfig, handler func(ch ssh.Channel, command string)) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer ch.Close()
+			for req := range chReqs {
+				if req.Type != "exec" {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				_ = ssh.Unmarshal(req.Payload, &payload)
+				_ = req.Reply(true, nil)
+				go handler(ch, payload.Command)
+			}
+		}()
+	}
+}
+
+// exitStatus 发送退出码并关闭 channel
+func exitStatus(ch ssh.Channel, code uint32) {
+	_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{code}))
+	_ = ch.Close()
+}
+
+func TestRunCommand(t *testing.T) {
+	client := startExecServer(t, func(ch ssh.Channel, command string) {
+		_, _ = ch.Write([]byte("out: " + command + "\n"))
+		_, _ = ch.Stderr().Write([]byte("err\n"))
+		exitStatus(ch, 3)
+	})
+
+	r := runCommand(context.Background(), client, "id", time.Second)
+	if r.ExitCode != 3 || r.Stdout != "out: id\n" || r.Stderr != "err\n" || r.Error != "" {
+		t.Errorf("result = %+v", r)
+	}
+}
+
+func TestRunCommandTruncated(t *testing.T) {
+	client := startExecServer(t, func(ch ssh.Channel, _ string) {
+		_, _ = ch.Write(make([]byte, maxOutputBytes+1))
+		exitStatus(ch, 0)
+	})
+
+	r := runCommand(context.Background(), client, "cat big", time.Second)
+	if r.ExitCode != 0 || !strings.HasSuffix(r.Stdout, "\n...(truncated)") || len(r.Stdout) != maxOutputBytes+len("\n...(truncated)") {
+		t.Errorf("exit = %d, stdout %d bytes", r.ExitCode, len(r.Stdout))
+	}
+}
+
+// 命令一直输出直到超时, 超时后 session 的复制 goroutine 还在写入缓冲区, 需要在 -race 下运行
+func TestRunCommandTimeoutWhileWriting(t *testing.T) {
+	client := startExecServer(t, func(ch ssh.Channel, _ string) {
+		for {
+			if _, err := ch.Write([]byte("tick\n")); err != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	start := time.Now()
+	r := runCommand(context.Background(), client, "yes tick", 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("runCommand returned after %v", elapsed)
+	}
+	if r.ExitCode != -1 || !strings.Contains(r.Error, "timed out") {
+		t.Errorf("exit = %d, error = %q, want timeout", r.ExitCode, r.Error)
+	}
+	if !strings.HasPrefix(r.Stdout, "tick\n") {
+		t.Errorf("stdout = %q, want output before timeout", r.Stdout[:min(len(r.Stdout), 20)])
+	}
+}
+
+func TestRunCommandCancel(t *testing.T) {
+	client := startExecServer(t, func(ch ssh.Channel, _ string) {
+		for {
+			if _, err := ch.Write([]byte("tick\n")); err != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	r := runCommand(ctx, client, "yes tick", 10*time.Second)
+	if r.ExitCode != -1 || r.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("exit = %d, error = %q, want context deadline", r.ExitCode, r.Error)
+	}
+}
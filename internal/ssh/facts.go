@@ -0,0 +1,115 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/formatter"
+	"golang.org/x/crypto/ssh"
+)
+
+// 登录成功后收集主机信息
+// 只使用 POSIX sh、uname、awk、df 和 /proc, BusyBox 上也能运行
+
+// factsScript 每行输出一个 key=value, 拿不到的值为空
+const factsScript = `echo "hostname=$(uname -n)"
+echo "os=$(uname -s)"
+echo "kernel=$(uname -r)"
+echo "arch=$(uname -m)"
+if [ -r /etc/os-release ]; then (. /etc/os-release; echo "distro=${PRETTY_NAME:-$NAME $VERSION}"); fi
+echo "uptime=$(cut -d' ' -f1 /proc/uptime 2>/dev/null)"
+iface=$(awk '$2 == "00000000" { print $1; exit }' /proc/net/route 2>/dev/null)
+echo "interface=$iface"
+if [ -n "$iface" ]; then echo "mac=$(cat /sys/class/net/$iface/address 2>/dev/null)"; fi
+echo "mem_kb=$(awk '/^MemTotal:/ { print $2 }' /proc/meminfo 2>/dev/null)"
+echo "disk_kb=$(df -Pk 2>/dev/null | awk '$1 ~ /^\/dev\// && !seen[$1]++ { s += $2 } END { print s + 0 }')"
+`
+
+// Facts 是主机的基本信息
+type Facts struct {
+	Hostname      string `json:"hostname"`
+	OS            string `json:"os"`               // uname -s, 例如 Linux
+	Distro        string `json:"distro,omitempty"` // /etc/os-release 中的发行版名称
+	Kernel        string `json:"kernel"`
+	Arch          string `json:"arch"`
+	UptimeSeconds int64  `json:"uptime_seconds,omitempty"`
+	Interface     string `json:"interface,omitempty"` // 默认路由所在的网卡
+	MAC           string `json:"mac,omitempty"`       // 默认路由所在网卡的 MAC 地址
+	MemoryBytes   uint64 `json:"memory_bytes,omitempty"`
+	DiskBytes     uint64 `json:"disk_bytes,omitempty"` // 所有已挂载的块设备容量之和
+	Error         string `json:"error,omitempty"`      // 收集失败的原因, 已收集到的信息仍然保留
+}
+
+// gatherFacts 执行 factsScript 并解析输出
+func gatherFacts(ctx context.Context, client *ssh.Client, timeout time.Duration) *Facts {
+	r := runCommand(ctx, client, factsScript, timeout)
+	facts := &Facts{Error: r.Error}
+
+	scanner := bufio.NewScanner(strings.NewReader(r.Stdout))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "hostname":
+			facts.Hostname = value
+		case "os":
+			facts.OS = value
+		case "kernel":
+			facts.Kernel = value
+		case "arch":
+			facts.Arch = value
+		case "distro":
+			facts.Distro = value
+		case "uptime":
+			// /proc/uptime 中的秒数带小数
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+				facts.UptimeSeconds = int64(seconds)
+			}
+		case "interface":
+			facts.Interface = value
+		case "mac":
+			facts.MAC = value
+		case "mem_kb":
+			facts.MemoryBytes = parseKB(value)
+		case "disk_kb":
+			facts.DiskBytes = parseKB(value)
+		}
+	}
+	if facts.Hostname == "" && facts.Error == "" {
+		facts.Error = fmt.Sprintf("unexpected output, exit %d: %s", r.ExitCode, strings.TrimSpace(r.Stderr))
+	}
+	return facts
+}
+
+func parseKB(value string) uint64 {
+	kb, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return kb * 1024
+}
+
+// printFacts 在一行中输出主机信息
+func printFacts(f *Facts) {
+	if f == nil {
+		return
+	}
+	if f.Error != "" {
+		fmt.Printf("  ! %s\n", f.Error)
+	}
+	os := f.Distro
+	if os == "" {
+		os = f.OS
+	}
+	fmt.Printf("  %s\t%s\t%s %s\tmem %s\tdisk %s\tmac %s\tup %v\n",
+		f.Hostname, os, f.Kernel, f.Arch,
+		formatter.BinaryBytes(float64(f.MemoryBytes), 1), formatter.BinaryBytes(float64(f.DiskBytes), 1),
+		f.MAC, time.Duration(f.UptimeSeconds)*time.Second)
+}
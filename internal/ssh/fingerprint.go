@@ -0,0 +1,214 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 服务器指纹: 版本 banner 和算法
+// 握手时服务器发送的 banner 和 KEXINIT 都是明文, 通过包装 net.Conn 记录下来,
+// 这样即使登录失败也能拿到服务器提供的算法
+
+// 记录握手数据时最多缓存的字节数, 超过后放弃解析
+const maxSniffBytes = 64 * 1024
+
+// Algorithms 是一组 SSH 算法列表
+type Algorithms struct {
+	KeyExchanges []string `json:"kex"`
+	HostKeys     []string `json:"host_key"`
+	Ciphers      []string `json:"ciphers"`
+	MACs         []string `json:"macs"`
+	Compressions []string `json:"compressions"`
+}
+
+// NegotiatedAlgorithms 是协商出的算法(客户端到服务器方向)
+type NegotiatedAlgorithms struct {
+	KeyExchange string `json:"kex"`
+	HostKey     string `json:"host_key"`
+	Cipher      string `json:"cipher"`
+	MAC         string `json:"mac,omitempty"` // AEAD 加密算法不需要单独的 MAC
+	Compression string `json:"compression"`
+}
+
+// ServerAlgorithms 是服务器提供的算法和协商结果
+type ServerAlgorithms struct {
+	Offered    Algorithms            `json:"offered"`
+	Negotiated *NegotiatedAlgorithms `json:"negotiated,omitempty"`
+}
+
+// 自带认证的加密算法, 协商时不需要 MAC
+var aeadCiphers = []string{
+	"aes128-gcm@openssh.com",
+	"aes256-gcm@openssh.com",
+	"chacha20-poly1305@openssh.com",
+}
+
+// kexInitMsg 对应 RFC 4253 7.1 中的 SSH_MSG_KEXINIT
+type kexInitMsg struct {
+	Cookie                  [16]byte `sshtype:"20"`
+	KexAlgos                []string
+	ServerHostKeyAlgos      []string
+	CiphersClientServer     []string
+	CiphersServerClient     []string
+	MACsClientServer        []string
+	MACsServerClient        []string
+	CompressionClientServer []string
+	CompressionServerClient []string
+	LanguagesClientServer   []string
+	LanguagesServerClient   []string
+	FirstKexFollows         bool
+	Reserved                uint32
+}
+
+func (m *kexInitMsg) algorithms() Algorithms {
+	return Algorithms{
+		KeyExchanges: m.KexAlgos,
+		HostKeys:     m.ServerHostKeyAlgos,
+		Ciphers:      mergeNames(m.CiphersClientServer, m.CiphersServerClient),
+		MACs:         mergeNames(m.MACsClientServer, m.MACsServerClient),
+		Compressions: mergeNames(m.CompressionClientServer, m.CompressionServerClient),
+	}
+}
+
+// negotiate 按 RFC 4253 的规则协商算法: 取客户端列表中第一个服务器也支持的算法
+func negotiate(client, server *kexInitMsg) *NegotiatedAlgorithms {
+	n := &NegotiatedAlgorithms{
+		KeyExchange: findCommon(client.KexAlgos, server.KexAlgos),
+		HostKey:     findCommon(client.ServerHostKeyAlgos, server.ServerHostKeyAlgos),
+		Cipher:      findCommon(client.CiphersClientServer, server.CiphersClientServer),
+		Compression: findCommon(client.CompressionClientServer, server.CompressionClientServer),
+	}
+	if !slices.Contains(aeadCiphers, n.Cipher) {
+		n.MAC = findCommon(client.MACsClientServer, server.MACsClientServer)
+	}
+	return n
+}
+
+func findCommon(client, server []string) string {
+	for _, c := range client {
+		if slices.Contains(server, c) {
+			return c
+		}
+	}
+	return ""
+}
+
+func mergeNames(a, b []string) []string {
+	result := slices.Clone(a)
+	for _, name := range b {
+		if !slices.Contains(result, name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// handshakeSniffer 从一个方向的数据流中解析 banner 和第一个 KEXINIT
+type handshakeSniffer struct {
+	buf     []byte
+	done    bool
+	total   int // 收到的总字节数
+	banner  string
+	kexInit *kexInitMsg
+}
+
+func (s *handshakeSniffer) feed(p []byte) {
+	s.total += len(p)
+	if s.done {
+		return
+	}
+	s.buf = append(s.buf, p...)
+	if len(s.buf) > maxSniffBytes {
+		s.finish()
+		return
+	}
+
+	// banner 之前可能还有其他文本行
+	for s.banner == "" {
+		idx := bytes.IndexByte(s.buf, '\n')
+		if idx < 0 {
+			return
+		}
+		line := strings.TrimRight(string(s.buf[:idx]), "\r")
+		s.buf = s.buf[idx+1:]
+		if strings.HasPrefix(line, "SSH-") {
+			s.banner = line
+		}
+	}
+
+	// 二进制包: uint32 packet_length, byte padding_length, payload, padding
+	if len(s.buf) < 5 {
+		return
+	}
+	length := int(binary.BigEndian.Uint32(s.buf))
+	if length > maxSniffBytes {
+		s.finish()
+		return
+	}
+	if len(s.buf) < 4+length {
+		return
+	}
+	padding := int(s.buf[4])
+	if padding+1 <= length {
+		msg := &kexInitMsg{}
+		if err := ssh.Unmarshal(s.buf[5:4+length-padding], msg); err == nil {
+			s.kexInit = msg
+		}
+	}
+	s.finish()
+}
+
+func (s *handshakeSniffer) finish() {
+	s.done = true
+	s.buf = nil
+}
+
+// sniffConn 记录握手阶段双方发送的 banner 和 KEXINIT
+type sniffConn struct {
+	net.Conn
+	mu             sync.Mutex
+	server, client handshakeSniffer
+}
+
+func (c *sniffConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	c.server.feed(p[:n])
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *sniffConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	c.client.feed(p)
+	c.mu.Unlock()
+	return c.Conn.Write(p)
+}
+
+// received 判断是否收到了服务器的数据
+func (c *sniffConn) received() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.server.total > 0
+}
+
+// result 返回服务器的 banner 和算法, 没有收到服务器的 KEXINIT 时算法为 nil
+func (c *sniffConn) result() (string, *ServerAlgorithms) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.server.kexInit == nil {
+		return c.server.banner, nil
+	}
+	algs := &ServerAlgorithms{Offered: c.server.kexInit.algorithms()}
+	if c.client.kexInit != nil {
+		algs.Negotiated = negotiate(c.client.kexInit, c.server.kexInit)
+	}
+	return c.server.banner, algs
+}
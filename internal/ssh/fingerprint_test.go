@@ -0,0 +1,139 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var testKexInit = kexInitMsg{
+	KexAlgos:                []string{"curve25519-sha256", "diffie-hellman-group14-sha256"},
+	ServerHostKeyAlgos:      []string{"ssh-ed25519", "rsa-sha2-512"},
+	CiphersClientServer:     []string{"chacha20-poly1305@openssh.com", "aes128-ctr"},
+	CiphersServerClient:     []string{"aes128-ctr", "aes256-ctr"},
+	MACsClientServer:        []string{"hmac-sha2-256"},
+	MACsServerClient:        []string{"hmac-sha2-256"},
+	CompressionClientServer: []string{"none"},
+	CompressionServerClient: []string{"none", "zlib@openssh.com"},
+}
+
+// packet 把 payload 封装成未加密的二进制包
+func packet(payload []byte, padding int) []byte {
+	b := make([]byte, 5, 5+len(payload)+padding)
+	binary.BigEndian.PutUint32(b, uint32(1+len(payload)+padding))
+	b[4] = byte(padding)
+	b = append(b, payload...)
+	return append(b, make([]byte, padding)...)
+}
+
+func TestHandshakeSnifferFeed(t *testing.T) {
+	kexInit := packet(ssh.Marshal(&testKexInit), 8)
+	cat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	tests := []struct {
+		name       string
+		data       []byte
+		chunk      int // 每次 feed 的字节数, 0 表示一次全部
+		wantBanner string
+		wantKex    bool
+	}{
+		{"banner and kexinit", cat([]byte("SSH-2.0-OpenSSH_9.6\r\n"), kexInit), 0, "SSH-2.0-OpenSSH_9.6", true},
+		{"one byte at a time", cat([]byte("SSH-2.0-OpenSSH_9.6\r\n"), kexInit), 1, "SSH-2.0-OpenSSH_9.6", true},
+		{"split chunks", cat([]byte("SSH-2.0-OpenSSH_9.6\r\n"), kexInit), 7, "SSH-2.0-OpenSSH_9.6", true},
+		{"lf only", cat([]byte("SSH-2.0-dropbear\n"), kexInit), 0, "SSH-2.0-dropbear", true},
+		{"lines before banner", cat([]byte("Welcome\r\nauthorized use only\r\nSSH-2.0-Cisco-1.25\r\n"), kexInit), 3, "SSH-2.0-Cisco-1.25", true},
+		{"banner only", []byte("SSH-2.0-OpenSSH_9.6\r\n"), 0, "SSH-2.0-OpenSSH_9.6", false},
+		{"incomplete packet", cat([]byte("SSH-2.0-OpenSSH_9.6\r\n"), kexInit[:len(kexInit)-1]), 0, "SSH-2.0-OpenSSH_9.6", false},
+		{"not kexinit", cat([]byte("SSH-2.0-OpenSSH_9.6\r\n"), packet([]byte{21}, 4)), 0, "SSH-2.0-OpenSSH_9.6", false},
+		{"padding longer than packet", cat([]byte("SSH-2.0-OpenSSH_9.6\r\n"), []byte{0, 0, 0, 2, 9, 20, 0}), 0, "SSH-2.0-OpenSSH_9.6", false},
+		{"packet too large", cat([]byte("SSH-2.0-OpenSSH_9.6\r\n"), []byte{0x7f, 0, 0, 0, 4}), 0, "SSH-2.0-OpenSSH_9.6", false},
+		{"not ssh", []byte("220 mail.example.com ESMTP\r\n"), 0, "", false},
+		{"no newline", bytes.Repeat([]byte("x"), maxSniffBytes+1), 1024, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s handshakeSniffer
+			chunk := tt.chunk
+			if chunk == 0 {
+				chunk = len(tt.data)
+			}
+			for data := tt.data; len(data) > 0; {
+				n := min(chunk, len(data))
+				s.feed(data[:n])
+				data = data[n:]
+			}
+
+			if s.banner != tt.wantBanner {
+				t.Errorf("banner = %q, want %q", s.banner, tt.wantBanner)
+			}
+			if s.total != len(tt.data) {
+				t.Errorf("total = %d, want %d", s.total, len(tt.data))
+			}
+			if !tt.wantKex {
+				if s.kexInit != nil {
+					t.Errorf("unexpected kexinit %+v", s.kexInit)
+				}
+				return
+			}
+			if s.kexInit == nil {
+				t.Fatal("kexinit not parsed")
+			}
+			if got, want := s.kexInit.algorithms(), testKexInit.algorithms(); !reflect.DeepEqual(got, want) {
+				t.Errorf("algorithms = %+v, want %+v", got, want)
+			}
+			if !s.done || s.buf != nil {
+				t.Error("sniffer not finished after kexinit")
+			}
+		})
+	}
+}
+
+func TestHandshakeSnifferDone(t *testing.T) {
+	var s handshakeSniffer
+	s.feed([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+	s.feed(packet(ssh.Marshal(&testKexInit), 8))
+	// 握手之后的数据只计入总字节数
+	s.feed([]byte("SSH-2.0-other\r\n"))
+	if s.banner != "SSH-2.0-OpenSSH_9.6" || s.buf != nil {
+		t.Errorf("banner = %q, buf = %d bytes after done", s.banner, len(s.buf))
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	client := &kexInitMsg{
+		KexAlgos:                []string{"sntrup761x25519-sha512@openssh.com", "curve25519-sha256"},
+		ServerHostKeyAlgos:      []string{"rsa-sha2-512", "ssh-ed25519"},
+		CiphersClientServer:     []string{"aes128-ctr", "chacha20-poly1305@openssh.com"},
+		MACsClientServer:        []string{"hmac-sha2-512", "hmac-sha2-256"},
+		CompressionClientServer: []string{"none"},
+	}
+	want := &NegotiatedAlgorithms{
+		KeyExchange: "curve25519-sha256",
+		HostKey:     "rsa-sha2-512",
+		Cipher:      "aes128-ctr",
+		MAC:         "hmac-sha2-256",
+		Compression: "none",
+	}
+	if got := negotiate(client, &testKexInit); !reflect.DeepEqual(got, want) {
+		t.Errorf("negotiate = %+v, want %+v", got, want)
+	}
+
+	// AEAD 加密算法不协商 MAC
+	client.CiphersClientServer = []string{"chacha20-poly1305@openssh.com"}
+	if got := negotiate(client, &testKexInit); got.Cipher != "chacha20-poly1305@openssh.com" || got.MAC != "" {
+		t.Errorf("negotiate = %+v, want chacha20 without mac", got)
+	}
+}
+
+func TestAlgorithmsMergeDirections(t *testing.T) {
+	algs := testKexInit.algorithms()
+	if want := []string{"chacha20-poly1305@openssh.com", "aes128-ctr", "aes256-ctr"}; !reflect.DeepEqual(algs.Ciphers, want) {
+		t.Errorf("ciphers = %v, want %v", algs.Ciphers, want)
+	}
+	if want := []string{"none", "zlib@openssh.com"}; !reflect.DeepEqual(algs.Compressions, want) {
+		t.Errorf("compressions = %v, want %v", algs.Compressions, want)
+	}
+}
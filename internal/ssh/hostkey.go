@@ -0,0 +1,180 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// known_hosts 校验结果
+const (
+	HostKeyMatch   = "match"   // 和 known_hosts 中记录的一致
+	HostKeyNew     = "new"     // known_hosts 中没有这台主机
+	HostKeyChanged = "changed" // 和 known_hosts 中记录的同类型密钥不一致, 可能是重装了系统或者被冒充
+	// known_hosts 中只记录了这台主机其他类型的密钥, 例如服务器不支持记录的 ed25519, 提供了 ECDSA 密钥
+	HostKeyOtherType = "other_type"
+)
+
+var ErrHostKeyChanged = errors.New("HostKeyChanged")
+
+// HostKey 是服务器的主机密钥
+type HostKey struct {
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`      // SHA256 指纹
+	Key         string `json:"key"`              // base64 编码的公钥
+	Status      string `json:"status,omitempty"` // known_hosts 校验结果, 未启用校验时为空
+}
+
+func newHostKey(key ssh.PublicKey) *HostKey {
+	return &HostKey{
+		Type:        key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		Key:         base64.StdEncoding.EncodeToString(key.Marshal()),
+	}
+}
+
+// KnownHosts 用 known_hosts 文件校验主机密钥
+type KnownHosts struct {
+	path     string
+	callback ssh.HostKeyCallback
+}
+
+// LoadKnownHosts 读取 known_hosts 文件, path 以 ~/ 开头时相对于用户主目录
+func LoadKnownHosts(path string) (*KnownHosts, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("load known_hosts: %w", err)
+	}
+	return &KnownHosts{path: path, callback: callback}, nil
+}
+
+// check 返回主机密钥的校验结果
+// knownhosts 的 KeyError.Want 包含这台主机所有类型的密钥, 只有存在同类型的密钥时才算变化
+func (k *KnownHosts) check(hostname string, remote net.Addr, key ssh.PublicKey) string {
+	err := k.callback(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	switch {
+	case err == nil:
+		return HostKeyMatch
+	case !errors.As(err, &keyErr) || len(keyErr.Want) == 0:
+		return HostKeyNew
+	}
+	for _, want := range keyErr.Want {
+		if want.Key.Type() == key.Type() {
+			return HostKeyChanged
+		}
+	}
+	return HostKeyOtherType
+}
+
+// hostKeyAlgorithms 和 OpenSSH 一样优先协商 known_hosts 中记录的密钥类型, 然后是其他支持的算法;
+// 否则 x/crypto 默认优先使用 ECDSA, 记录了 ed25519 密钥的主机会提供另一个密钥, 无法校验
+// known_hosts 中没有这台主机时返回 nil, 使用默认顺序
+func (k *KnownHosts) hostKeyAlgorithms(ipPort string) []string {
+	if k == nil {
+		return nil
+	}
+	// 用一个不可能出现在文件中的密钥查询, KeyError.Want 就是这台主机记录的所有密钥
+	probe, err := probeKey()
+	if err != nil {
+		return nil
+	}
+	host, port, _ := net.SplitHostPort(ipPort)
+	portNum, _ := strconv.Atoi(port)
+	var keyErr *knownhosts.KeyError
+	if !errors.As(k.callback(ipPort, &net.TCPAddr{IP: net.ParseIP(host), Port: portNum}, probe), &keyErr) || len(keyErr.Want) == 0 {
+		return nil
+	}
+
+	var algos []string
+	for _, want := range keyErr.Want {
+		for _, algo := range keyTypeAlgorithms(want.Key.Type()) {
+			if !slices.Contains(algos, algo) {
+				algos = append(algos, algo)
+			}
+		}
+	}
+	for _, algo := range ssh.SupportedAlgorithms().HostKeys {
+		if !slices.Contains(algos, algo) {
+			algos = append(algos, algo)
+		}
+	}
+	return algos
+}
+
+// keyTypeAlgorithms 返回可以用来协商某种类型主机密钥的算法, RSA 密钥对应多种签名算法
+func keyTypeAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// probeKey 是查询 known_hosts 用的随机密钥, 只生成一次
+var probeKey = sync.OnceValues(func() (ssh.PublicKey, error) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewPublicKey(pub)
+})
+
+// hostKeyCallback 记录服务器的主机密钥; 启用 known_hosts 校验时, 主机密钥变化的服务器会被中断连接, 避免把凭据发送给冒充的主机
+func (opt Option) hostKeyCallback(info *ConnInfo) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		info.HostKey = newHostKey(key)
+		if opt.KnownHosts == nil {
+			return nil
+		}
+		info.HostKey.Status = opt.KnownHosts.check(hostname, remote, key)
+		if info.HostKey.Status == HostKeyChanged {
+			return ErrHostKeyChanged
+		}
+		return nil
+	}
+}
+
+// WriteKnownHosts 把扫描到的主机密钥写入 known_hosts 文件, 文件已存在时会被覆盖
+func WriteKnownHosts(path string, results []ScanResult) error {
+	path, err := expandHome(path)
+	if err != nil {
+		return err
+	}
+
+	sortResults(results)
+	var sb strings.Builder
+	for _, r := range results {
+		if r.HostKey == nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s %s %s\n", knownhosts.Normalize(r.IP), r.HostKey.Type, r.HostKey.Key))
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0o600)
+}
+
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("fetch user home dir: %w", err)
+	}
+	return filepath.Join(homeDir, path[2:]), nil
+}
@@ -0,0 +1,121 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// 未指定私钥文件时按顺序查找的默认私钥
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+var ErrNoIdentity = errors.New("no private key found")
+
+// IdentityOption 描述私钥来源
+type IdentityOption struct {
+	Files         []string // 私钥文件, 为空且不使用 agent 时自动查找 ~/.ssh 下的默认私钥
+	PassphraseEnv string   // 保存私钥密码的环境变量名, 为空时在终端提示输入
+	UseAgent      bool     // 是否使用 ssh-agent 中的身份
+	AgentSocket   string   // ssh-agent 的 socket 路径, 为空时使用 SSH_AUTH_SOCK
+}
+
+// LoadSigners 读取并解析所有私钥, 任何一个私钥读取失败都会返回错误
+// agent 中的身份排在私钥文件之前
+func LoadSigners(opt IdentityOption) ([]ssh.Signer, error) {
+	var signers []ssh.Signer
+	if opt.UseAgent {
+		agentSigners, err := AgentSigners(opt.AgentSocket)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, agentSigners...)
+	}
+
+	files := opt.Files
+	if len(files) == 0 {
+		if opt.UseAgent {
+			return signers, nil
+		}
+		var err error
+		files, err = discoverIdentityFiles()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range files {
+		signer, err := loadSigner(path, opt.PassphraseEnv)
+		if err != nil {
+			return nil, fmt.Errorf("load private key %s: %w", path, err)
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// discoverIdentityFiles 查找 ~/.ssh 下存在的默认私钥
+func discoverIdentityFiles() ([]string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("fetch user home dir: %w", err)
+	}
+
+	var files []string
+	for _, name := range defaultIdentityFiles {
+		path := filepath.Join(homeDir, ".ssh", name)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoIdentity, filepath.Join(homeDir, ".ssh"))
+	}
+	return files, nil
+}
+
+func loadSigner(path string, passphraseEnv string) (ssh.Signer, error) {
+	keyBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+	var missingErr *ssh.PassphraseMissingError
+	if !errors.As(err, &missingErr) {
+		return signer, err
+	}
+
+	// 私钥有密码保护
+	passphrase, err := readPassphrase(path, passphraseEnv)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKeyWithPassphrase(keyBytes, passphrase)
+}
+
+// readPassphrase 从环境变量读取私钥密码, 没有指定环境变量时在终端提示输入
+func readPassphrase(path string, passphraseEnv string) ([]byte, error) {
+	if passphraseEnv != "" {
+		passphrase, ok := os.LookupEnv(passphraseEnv)
+		if !ok {
+			return nil, fmt.Errorf("private key is encrypted but $%s is not set", passphraseEnv)
+		}
+		return []byte(passphrase), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("private key is encrypted and stdin is not a terminal, use --identity-passphrase-env")
+	}
+	fmt.Fprintf(os.Stderr, "请输入私钥 %s 的密码: ", path)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("read passphrase: %w", err)
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,176 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 严重程度, 从高到低排列
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+	SeverityInfo   = "info"
+	SeverityOK     = "ok" // 明确允许的算法, 不产生 finding, 用来在自定义策略中放行内置策略标记的算法
+)
+
+var severities = []string{SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo, SeverityOK}
+
+// 算法类别
+const (
+	CategoryKex         = "kex"
+	CategoryHostKey     = "host_key"
+	CategoryCipher      = "cipher"
+	CategoryMAC         = "mac"
+	CategoryCompression = "compression"
+)
+
+var categories = []string{CategoryKex, CategoryHostKey, CategoryCipher, CategoryMAC, CategoryCompression}
+
+// Rule 是一条审计规则
+type Rule struct {
+	Category string `yaml:"category" json:"category"`
+	Pattern  string `yaml:"pattern" json:"pattern"` // 算法名, 支持 * 和 ? 通配符
+	Severity string `yaml:"severity" json:"severity"`
+	Reason   string `yaml:"reason" json:"reason"`
+}
+
+// Policy 是算法审计策略, 每个算法使用第一条匹配的规则
+type Policy struct {
+	// 是否在自定义规则之后追加内置规则, 自定义规则优先
+	IncludeDefault bool   `yaml:"include_default" json:"include_default"`
+	Rules          []Rule `yaml:"rules" json:"rules"`
+}
+
+// Finding 是审计发现的一个问题
+type Finding struct {
+	Category   string `json:"category"`
+	Algorithm  string `json:"algorithm"`
+	Severity   string `json:"severity"`
+	Reason     string `json:"reason"`
+	Negotiated bool   `json:"negotiated,omitempty"` // 本次连接实际协商使用了这个算法
+}
+
+// DefaultPolicy 是内置的审计策略
+var DefaultPolicy = Policy{Rules: []Rule{
+	{CategoryKex, "diffie-hellman-group1-sha1", SeverityHigh, "1024 位 DH 组, 易受 Logjam 攻击"},
+	{CategoryKex, "diffie-hellman-group-exchange-sha1", SeverityMedium, "使用 SHA1, 且服务器可能选择过小的 DH 组"},
+	{CategoryKex, "rsa1024-sha1", SeverityHigh, "1024 位 RSA 密钥交换"},
+	{CategoryKex, "gss-*-sha1-*", SeverityMedium, "使用 SHA1"},
+	{CategoryKex, "*-sha1", SeverityLow, "使用 SHA1"},
+
+	{CategoryHostKey, "ssh-dss*", SeverityHigh, "DSA 密钥只有 1024 位, OpenSSH 7.0 起默认禁用"},
+	{CategoryHostKey, "ssh-rsa", SeverityLow, "使用 SHA1 签名, OpenSSH 8.8 起默认禁用"},
+	{CategoryHostKey, "ssh-rsa-cert-v01@openssh.com", SeverityLow, "使用 SHA1 签名"},
+
+	{CategoryCipher, "none", SeverityHigh, "不加密"},
+	{CategoryCipher, "arcfour*", SeverityHigh, "RC4 存在已知偏差, 不安全"},
+	{CategoryCipher, "3des-cbc", SeverityHigh, "64 位分组, 易受 Sweet32 攻击"},
+	{CategoryCipher, "blowfish-cbc", SeverityHigh, "64 位分组, 易受 Sweet32 攻击"},
+	{CategoryCipher, "cast128-cbc", SeverityHigh, "64 位分组, 易受 Sweet32 攻击"},
+	{CategoryCipher, "*-cbc", SeverityMedium, "CBC 模式易受明文恢复攻击"},
+	{CategoryCipher, "rijndael-cbc@lysator.liu.se", SeverityMedium, "CBC 模式易受明文恢复攻击"},
+
+	{CategoryMAC, "none", SeverityHigh, "没有完整性校验"},
+	{CategoryMAC, "hmac-md5*", SeverityHigh, "MD5 已被攻破"},
+	{CategoryMAC, "hmac-ripemd160*", SeverityLow, "已被 OpenSSH 移除"},
+	{CategoryMAC, "umac-64*", SeverityLow, "64 位标签过短"},
+	{CategoryMAC, "*-96*", SeverityMedium, "截断到 96 位"},
+	{CategoryMAC, "hmac-sha1", SeverityLow, "使用 SHA1, 且不是 encrypt-then-mac"},
+}}
+
+// LoadPolicy 读取 YAML 或 JSON 格式的审计策略文件
+func LoadPolicy(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open policy file: %w", err)
+	}
+	defer f.Close()
+
+	policy := &Policy{}
+	// JSON 是 YAML 的子集, 两种格式都用 YAML 解析
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(policy); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err = policy.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// 合并后的规则已经完整, 清除标记, 避免 --dump-policy 输出的策略再次加载时重复追加内置规则
+	if policy.IncludeDefault {
+		policy.Rules = append(policy.Rules, DefaultPolicy.Rules...)
+		policy.IncludeDefault = false
+	}
+	return policy, nil
+}
+
+func (p *Policy) validate() error {
+	for i, r := range p.Rules {
+		if !slices.Contains(categories, r.Category) {
+			return fmt.Errorf("rule %d: unknown category %q, should be one of %v", i+1, r.Category, categories)
+		}
+		if !slices.Contains(severities, r.Severity) {
+			return fmt.Errorf("rule %d: unknown severity %q, should be one of %v", i+1, r.Severity, severities)
+		}
+		if _, err := path.Match(r.Pattern, ""); err != nil || r.Pattern == "" {
+			return fmt.Errorf("rule %d: invalid pattern %q", i+1, r.Pattern)
+		}
+	}
+	return nil
+}
+
+// Evaluate 用策略检查服务器提供的算法, 结果按严重程度从高到低排列
+func (p *Policy) Evaluate(algs *ServerAlgorithms) []Finding {
+	if algs == nil {
+		return nil
+	}
+	var negotiated NegotiatedAlgorithms
+	if algs.Negotiated != nil {
+		negotiated = *algs.Negotiated
+	}
+
+	var findings []Finding
+	check := func(category string, offered []string, used string) {
+		for _, name := range offered {
+			rule, ok := p.match(category, name)
+			if !ok || rule.Severity == SeverityOK {
+				continue
+			}
+			findings = append(findings, Finding{
+				Category:   category,
+				Algorithm:  name,
+				Severity:   rule.Severity,
+				Reason:     rule.Reason,
+				Negotiated: name == used,
+			})
+		}
+	}
+	check(CategoryKex, algs.Offered.KeyExchanges, negotiated.KeyExchange)
+	check(CategoryHostKey, algs.Offered.HostKeys, negotiated.HostKey)
+	check(CategoryCipher, algs.Offered.Ciphers, negotiated.Cipher)
+	check(CategoryMAC, algs.Offered.MACs, negotiated.MAC)
+	check(CategoryCompression, algs.Offered.Compressions, negotiated.Compression)
+
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return slices.Index(severities, a.Severity) - slices.Index(severities, b.Severity)
+	})
+	return findings
+}
+
+// match 返回第一条匹配的规则
+func (p *Policy) match(category, name string) (Rule, bool) {
+	for _, r := range p.Rules {
+		if r.Category != category {
+			continue
+		}
+		if ok, _ := path.Match(r.Pattern, name); ok {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
@@ -0,0 +1,140 @@
+package ssh
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEvaluate(t *testing.T) {
+	algs := &ServerAlgorithms{
+		Offered: Algorithms{
+			KeyExchanges: []string{"curve25519-sha256", "diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1"},
+			HostKeys:     []string{"ssh-ed25519", "rsa-sha2-512", "ssh-rsa"},
+			Ciphers:      []string{"chacha20-poly1305@openssh.com", "aes128-cbc", "3des-cbc"},
+			MACs:         []string{"hmac-sha2-256-etm@openssh.com", "hmac-sha1-96"},
+			Compressions: []string{"none", "zlib@openssh.com"},
+		},
+		Negotiated: &NegotiatedAlgorithms{
+			KeyExchange: "curve25519-sha256",
+			HostKey:     "ssh-rsa",
+			Cipher:      "aes128-cbc",
+			MAC:         "hmac-sha1-96",
+			Compression: "none",
+		},
+	}
+	want := []Finding{
+		{CategoryKex, "diffie-hellman-group1-sha1", SeverityHigh, "1024 位 DH 组, 易受 Logjam 攻击", false},
+		{CategoryCipher, "3des-cbc", SeverityHigh, "64 位分组, 易受 Sweet32 攻击", false},
+		{CategoryCipher, "aes128-cbc", SeverityMedium, "CBC 模式易受明文恢复攻击", true},
+		{CategoryMAC, "hmac-sha1-96", SeverityMedium, "截断到 96 位", true},
+		{CategoryKex, "diffie-hellman-group14-sha1", SeverityLow, "使用 SHA1", false},
+		{CategoryHostKey, "ssh-rsa", SeverityLow, "使用 SHA1 签名, OpenSSH 8.8 起默认禁用", true},
+	}
+	if got := DefaultPolicy.Evaluate(algs); !reflect.DeepEqual(got, want) {
+		t.Errorf("Evaluate:\n got %v\nwant %v", got, want)
+	}
+
+	// 没有协商结果时所有 finding 都不是 negotiated
+	algs.Negotiated = nil
+	for _, f := range DefaultPolicy.Evaluate(algs) {
+		if f.Negotiated {
+			t.Errorf("%s negotiated without handshake", f.Algorithm)
+		}
+	}
+
+	if got := DefaultPolicy.Evaluate(nil); got != nil {
+		t.Errorf("Evaluate(nil) = %v, want nil", got)
+	}
+}
+
+func TestEvaluateFirstMatch(t *testing.T) {
+	policy := Policy{Rules: []Rule{
+		{CategoryCipher, "aes128-cbc", SeverityOK, "内网设备, 允许"},
+		{CategoryCipher, "aes???-cbc", SeverityInfo, "CBC"},
+		{CategoryCipher, "*-cbc", SeverityHigh, "CBC"},
+		{CategoryMAC, "aes*-cbc", SeverityHigh, "类别不同, 不应匹配"},
+	}}
+	algs := &ServerAlgorithms{Offered: Algorithms{
+		Ciphers: []string{"aes128-cbc", "aes256-cbc", "3des-cbc", "aes128-ctr"},
+	}}
+	want := []Finding{
+		{CategoryCipher, "3des-cbc", SeverityHigh, "CBC", false},
+		{CategoryCipher, "aes256-cbc", SeverityInfo, "CBC", false},
+	}
+	if got := policy.Evaluate(algs); !reflect.DeepEqual(got, want) {
+		t.Errorf("Evaluate:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	yamlPolicy := writeFile(t, "policy.yaml", `include_default: true
+rules:
+  - category: host_key
+    pattern: ssh-rsa
+    severity: ok
+    reason: 旧设备只支持 ssh-rsa
+`)
+	policy, err := LoadPolicy(yamlPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Rules) != 1+len(DefaultPolicy.Rules) {
+		t.Fatalf("got %d rules, want %d", len(policy.Rules), 1+len(DefaultPolicy.Rules))
+	}
+	// 自定义规则在内置规则之前, 放行了 ssh-rsa
+	algs := &ServerAlgorithms{Offered: Algorithms{HostKeys: []string{"ssh-rsa", "ssh-dss"}}}
+	findings := policy.Evaluate(algs)
+	if len(findings) != 1 || findings[0].Algorithm != "ssh-dss" {
+		t.Errorf("findings = %v, want only ssh-dss", findings)
+	}
+
+	// 输出的策略重新加载后规则不变
+	out, err := yaml.Marshal(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadPolicy(writeFile(t, "dump.yaml", string(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reloaded.Rules, policy.Rules) {
+		t.Errorf("reloaded %d rules, want %d", len(reloaded.Rules), len(policy.Rules))
+	}
+
+	jsonPolicy := writeFile(t, "policy.json", `{"rules": [{"category": "mac", "pattern": "hmac-sha1", "severity": "high", "reason": "禁止 SHA1"}]}`)
+	policy, err = LoadPolicy(jsonPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{{CategoryMAC, "hmac-sha1", SeverityHigh, "禁止 SHA1"}}
+	if !reflect.DeepEqual(policy.Rules, want) {
+		t.Errorf("rules = %v, want %v", policy.Rules, want)
+	}
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown category", "rules: [{category: kexes, pattern: '*', severity: high}]", "unknown category"},
+		{"unknown severity", "rules: [{category: kex, pattern: '*', severity: critical}]", "unknown severity"},
+		{"empty pattern", "rules: [{category: kex, severity: high}]", "invalid pattern"},
+		{"bad pattern", "rules: [{category: kex, pattern: '[', severity: high}]", "invalid pattern"},
+		{"unknown field", "rules: [{category: kex, pattern: '*', severity: high, level: 1}]", "not found"},
+		{"not yaml", "rules: [", "policy.yaml"},
+		{"empty file", "", "policy.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPolicy(writeFile(t, "policy.yaml", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 通过 SCP 上传文件, 不依赖 SFTP 子系统
+// 协议: 远程执行 scp -t <dest>, 每一步都等待一个字节的应答, 0 表示成功, 1/2 后面跟着错误信息
+
+// PushAction 把本地文件上传到每台成功登录的主机
+type PushAction struct {
+	name    string // 本地文件名, 目标路径是目录时使用
+	content []byte
+	dest    string
+	mode    os.FileMode
+}
+
+// NewPushAction 读取要上传的本地文件, mode 为 0 时使用本地文件的权限
+func NewPushAction(localPath, dest string, mode os.FileMode) (*PushAction, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", localPath)
+	}
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if dest == "" {
+		return nil, errors.New("empty destination path")
+	}
+	if mode == 0 {
+		mode = info.Mode().Perm()
+	}
+	return &PushAction{
+		name:    filepath.Base(localPath),
+		content: content,
+		dest:    dest,
+		mode:    mode.Perm(),
+	}, nil
+}
+
+func (a *PushAction) Name() string {
+	return "push"
+}
+
+func (a *PushAction) Run(ctx context.Context, client *ssh.Client, host string, _ Credential, opt Option) *ActionResult {
+	result := &ActionResult{Action: a.Name()}
+
+	dest, err := a.resolveDest(ctx, client, opt.commandTimeout(host))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	if err = scpUpload(ctx, client, dest, a.content, a.mode, opt.commandTimeout(host)); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	// scp 创建文件时会受 umask 影响, 覆盖已有文件时不会修改权限, 上传后统一设置
+	chmod := runCommand(ctx, client, fmt.Sprintf("chmod %04o %s", a.mode, shellQuote(dest)), opt.commandTimeout(host))
+	if chmod.ExitCode != 0 {
+		result.Error = fmt.Sprintf("chmod: %s%s", chmod.Error, strings.TrimSpace(chmod.Stderr))
+		return result
+	}
+
+	result.OK = true
+	result.Message = fmt.Sprintf("%s (%d bytes, mode %04o)", dest, len(a.content), a.mode)
+	return result
+}
+
+// resolveDest 返回远程文件的完整路径: 目标是已有的目录时(不论是否以 / 结尾)在后面加上本地文件名,
+// 否则 scp 会写入目录下的文件, 而 chmod 修改的却是目录本身
+func (a *PushAction) resolveDest(ctx context.Context, client *ssh.Client, timeout time.Duration) (string, error) {
+	if strings.HasSuffix(a.dest, "/") {
+		return a.dest + a.name, nil
+	}
+	test := runCommand(ctx, client, "test -d "+shellQuote(a.dest), timeout)
+	switch {
+	case test.ExitCode == 0:
+		return path.Join(a.dest, a.name), nil
+	case test.ExitCode > 0:
+		return a.dest, nil
+	default:
+		return "", fmt.Errorf("test -d: %s", test.Error)
+	}
+}
+
+// scpUpload 把 content 写入远程文件 dest
+func scpUpload(ctx context.Context, client *ssh.Client, dest string, content []byte, mode os.FileMode, timeout time.Duration) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("new session: %w", err)
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr cappedBuffer
+	session.Stderr = &stderr
+
+	if err = session.Start("scp -t " + shellQuote(dest)); err != nil {
+		return fmt.Errorf("start scp: %w", err)
+	}
+
+	// 发送和等待 scp 退出都在 goroutine 中进行, 远程 scp 卡住时也受超时限制
+	doneCh := make(chan error, 1)
+	go func() {
+		if err := scpSend(stdin, bufio.NewReader(stdout), path.Base(dest), content, mode); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				doneCh <- fmt.Errorf("upload: %w: %s", err, msg)
+				return
+			}
+			doneCh <- fmt.Errorf("upload: %w", err)
+			return
+		}
+		doneCh <- session.Wait()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err = <-doneCh:
+		return err
+	case <-timer.C:
+		// 关闭 session 让 goroutine 返回
+		session.Close()
+		return fmt.Errorf("scp timed out after %v", timeout)
+	case <-ctx.Done():
+		session.Close()
+		return ctx.Err()
+	}
+}
+
+func scpSend(w io.WriteCloser, r *bufio.Reader, name string, content []byte, mode os.FileMode) error {
+	if err := scpAck(r); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "C%04o %d %s\n", mode.Perm(), len(content), name); err != nil {
+		return err
+	}
+	if err := scpAck(r); err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte{0}); err != nil {
+		return err
+	}
+	if err := scpAck(r); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// scpAck 读取远程 scp 的应答
+func scpAck(r *bufio.Reader) error {
+	code, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if code == 0 {
+		return nil
+	}
+	msg, _ := r.ReadString('\n')
+	return errors.New(strings.TrimSpace(msg))
+}
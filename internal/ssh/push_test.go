@@ -0,0 +1,107 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// scpServer 模拟远程的 test -d 和 scp -t, 记录收到的命令; stall 为 true 时 scp 收完文件后不退出
+type scpServer struct {
+	dirs  []string
+	stall bool
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func (s *scpServer) handle(ch ssh.Channel, command string) {
+	s.mu.Lock()
+	s.commands = append(s.commands, command)
+	s.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(command, "test -d "):
+		for _, dir := range s.dirs {
+			if command == "test -d "+shellQuote(dir) {
+				exitStatus(ch, 0)
+				return
+			}
+		}
+		exitStatus(ch, 1)
+	case strings.HasPrefix(command, "scp -t "):
+		r := bufio.NewReader(ch)
+		_, _ = ch.Write([]byte{0})
+		header, _ := r.ReadString('\n')
+		var mode, size int
+		var name string
+		_, _ = fmt.Sscanf(header, "C%o %d %s", &mode, &size, &name)
+		_, _ = ch.Write([]byte{0})
+		_, _ = r.Discard(size + 1)
+		_, _ = ch.Write([]byte{0})
+		if s.stall {
+			return
+		}
+		// 和真正的 scp 一样读到 EOF 才退出
+		_, _ = io.Copy(io.Discard, r)
+		exitStatus(ch, 0)
+	default:
+		exitStatus(ch, 0)
+	}
+}
+
+func (s *scpServer) history() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.commands)
+}
+
+func TestPushDirectoryWithoutSlash(t *testing.T) {
+	srv := &scpServer{dirs: []string{"/etc/app"}}
+	client := startExecServer(t, srv.handle)
+	opt := Option{}
+
+	tests := []struct {
+		dest, want string
+	}{
+		{"/etc/app", "/etc/app/app.conf"},
+		{"/etc/app/", "/etc/app/app.conf"},
+		{"/etc/app/other.conf", "/etc/app/other.conf"},
+	}
+	for _, tt := range tests {
+		a := &PushAction{name: "app.conf", content: []byte("x=1\n"), dest: tt.dest, mode: 0o644}
+		r := a.Run(context.Background(), client, "127.0.0.1", Credential{}, opt)
+		if !r.OK {
+			t.Fatalf("push %s: %s", tt.dest, r.Error)
+		}
+		if !strings.HasPrefix(r.Message, tt.want+" ") {
+			t.Errorf("push %s: message = %q, want path %s", tt.dest, r.Message, tt.want)
+		}
+		commands := srv.history()
+		if last := commands[len(commands)-1]; last != "chmod 0644 "+shellQuote(tt.want) {
+			t.Errorf("push %s: last command = %q, want chmod on %s", tt.dest, last, tt.want)
+		}
+	}
+}
+
+func TestScpUploadStalled(t *testing.T) {
+	srv := &scpServer{stall: true}
+	client := startExecServer(t, srv.handle)
+
+	start := time.Now()
+	err := scpUpload(context.Background(), client, "/tmp/f", []byte("x"), 0o600, 200*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("err = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("scpUpload returned after %v", elapsed)
+	}
+}
@@ -0,0 +1,132 @@
+package ssh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 批量修改密码
+// root 用户使用 chpasswd, 其他用户通过 passwd 修改自己的密码; 修改后用新密码重新登录验证
+
+// rotatePasswordScript 从标准输入读取旧密码和新密码, $1 是用户名
+// IFS 为空时 read 不会去掉密码首尾的空白
+const rotatePasswordScript = `IFS= read -r old
+IFS= read -r new
+if [ "$(id -u)" = 0 ] && command -v chpasswd >/dev/null 2>&1; then
+	printf '%s:%s\n' "$1" "$new" | chpasswd
+else
+	printf '%s\n%s\n%s\n' "$old" "$new" "$new" | passwd
+fi`
+
+// 审计记录中的状态
+const (
+	RotateDryRun     = "dry_run"    // 只列出将要修改的主机
+	RotateVerified   = "verified"   // 已修改并用新密码登录成功
+	RotateFailed     = "failed"     // 修改失败, 密码没有变化
+	RotateUnverified = "unverified" // 修改命令执行成功, 但新密码登录失败, 需要人工确认
+)
+
+// RotateRecord 是审计记录中的一行, 不包含任何密码
+type RotateRecord struct {
+	Time   time.Time `json:"time"`
+	Host   string    `json:"host"`
+	User   string    `json:"user"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// RotatePasswordAction 把成功登录的用户的密码修改为新密码
+type RotatePasswordAction struct {
+	newPassword string
+	dryRun      bool
+
+	mu       sync.Mutex
+	auditLog *os.File // 审计记录, 每行一个 JSON
+}
+
+// NewRotatePasswordAction 创建修改密码的操作, 审计记录追加到 auditPath
+func NewRotatePasswordAction(newPassword string, dryRun bool, auditPath string) (*RotatePasswordAction, error) {
+	if newPassword == "" {
+		return nil, errors.New("new password is empty")
+	}
+	if strings.ContainsAny(newPassword, "\r\n") {
+		return nil, errors.New("new password must not contain line breaks")
+	}
+	f, err := os.OpenFile(auditPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &RotatePasswordAction{newPassword: newPassword, dryRun: dryRun, auditLog: f}, nil
+}
+
+// Close 关闭审计记录文件
+func (a *RotatePasswordAction) Close() error {
+	return a.auditLog.Close()
+}
+
+func (a *RotatePasswordAction) Name() string {
+	return "rotate-password"
+}
+
+func (a *RotatePasswordAction) Run(ctx context.Context, client *ssh.Client, host string, cred Credential, opt Option) *ActionResult {
+	result := &ActionResult{Action: a.Name()}
+	record := RotateRecord{Host: host, User: cred.User}
+	defer func() {
+		record.Time = time.Now()
+		a.audit(record)
+	}()
+
+	switch {
+	case cred.Password == "":
+		// 公钥登录时不知道当前密码
+		record.Status, result.Error = RotateFailed, "not logged in with password, skipped"
+		return result
+	case cred.Password == a.newPassword:
+		record.Status, result.Error = RotateFailed, "new password is the same as the current one, skipped"
+		return result
+	case a.dryRun:
+		record.Status, result.OK = RotateDryRun, true
+		result.Message = fmt.Sprintf("dry-run: 将修改 %s 的密码", cred.User)
+		return result
+	}
+
+	command := fmt.Sprintf("sh -c %s sh %s", shellQuote(rotatePasswordScript), shellQuote(cred.User))
+	stdin := []byte(cred.Password + "\n" + a.newPassword + "\n")
+	r := runCommandInput(ctx, client, command, stdin, opt.commandTimeout(host))
+	if r.ExitCode != 0 {
+		record.Status = RotateFailed
+		record.Error = fmt.Sprintf("exit %d: %s", r.ExitCode, strings.TrimSpace(r.Error+" "+r.Stderr))
+		result.Error = record.Error
+		return result
+	}
+
+	// 用新密码重新登录验证
+	opt.Auth = Auth{Methods: []string{MethodPassword, MethodKeyboardInteractive}}
+	if _, err := TryConnectServerV2(ctx, host, Credential{User: cred.User, Password: a.newPassword}, opt); err != nil {
+		record.Status = RotateUnverified
+		record.Error = fmt.Sprintf("password changed but login with new password failed: %v", err)
+		result.Error = record.Error
+		return result
+	}
+	record.Status, result.OK = RotateVerified, true
+	result.Message = fmt.Sprintf("已修改 %s 的密码, 新密码登录验证成功", cred.User)
+	return result
+}
+
+// audit 写入一条审计记录, 写入失败时输出到标准错误
+func (a *RotatePasswordAction) audit(record RotateRecord) {
+	line, _ := json.Marshal(record)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.auditLog.Write(append(line, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "write audit log: %v\n", err)
+	}
+}
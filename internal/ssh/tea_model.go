@@ -10,32 +10,59 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
+// 扫描状态
+const (
+	StatusOK           = "ok"
+	StatusAuthError    = "auth_error"
+	StatusNetworkError = "network_error"
+	// 端口可以连接, 但不是 SSH 服务或者密钥交换失败
+	StatusHandshakeError = "handshake_error"
+	// 启用 known_hosts 校验时主机密钥和记录的不一致, 为了不泄漏凭据不会尝试登录
+	StatusHostKeyChanged = "host_key_changed"
+)
+
 // ScanResult 表示单个扫描结果
 type ScanResult struct {
-	IP     string
-	Status string // "ok", "auth_error", "network_error"
+	IP         string      `json:"ip"`
+	Status     string      `json:"status"`               // 见 Status* 常量
+	Reason     string      `json:"reason,omitempty"`     // 失败的详细原因, 见 Reason* 常量
+	Error      string      `json:"error,omitempty"`      // 失败时的原始错误
+	Credential *Credential `json:"credential,omitempty"` // 登录成功时使用的凭据
+	Method     string      `json:"method,omitempty"`     // 登录成功时使用的认证方式
+	Attempts   int         `json:"attempts"`             // 尝试的凭据数
+	Retries    int         `json:"retries,omitempty"`    // 因为暂时性的网络错误重新连接的次数
+	HostKey    *HostKey    `json:"host_key,omitempty"`   // 服务器的主机密钥
+	Banner     string      `json:"banner,omitempty"`     // 服务器的版本 banner
+	// 服务器提供的算法和协商结果
+	Algorithms *ServerAlgorithms `json:"algorithms,omitempty"`
+	Exec       *ExecResult       `json:"exec,omitempty"`   // 登录成功后执行的命令结果
+	Facts      *Facts            `json:"facts,omitempty"`  // 登录成功后收集的主机信息
+	Action     *ActionResult     `json:"action,omitempty"` // 登录成功后执行的操作结果
 }
 
 // TeaModel 是 bubbletea 的模型
 type TeaModel struct {
-	spinner      spinner.Model
-	scanning     bool
-	done         bool
-	okList       []string
-	authErrList  []string
-	networkList  []string
-	resultChan   chan ScanResult
-	doneChan     chan struct{}
-	ctx          context.Context
-	cancel       context.CancelFunc
-	totalScanned int
-	totalIPs     int
-	showAuth     bool
-	showNetwork  bool
+	spinner       spinner.Model
+	scanning      bool
+	done          bool
+	okList        []ScanResult
+	authErrList   []ScanResult
+	networkList   []ScanResult
+	handshakeList []ScanResult
+	changedList   []ScanResult
+	resultChan    chan ScanResult
+	doneChan      chan struct{}
+	ctx           context.Context
+	cancel        context.CancelFunc
+	totalScanned  int
+	totalIPs      int
+	showAuth      bool
+	showNetwork   bool
+	knownHosts    bool // 是否启用了 known_hosts 校验
 }
 
 // NewTeaModel 创建一个新的 TeaModel
-func NewTeaModel(ctx context.Context, totalIPs int, showAuth, showNetwork bool) *TeaModel {
+func NewTeaModel(ctx context.Context, totalIPs int, showAuth, showNetwork, knownHosts bool) *TeaModel {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
@@ -46,9 +73,9 @@ func NewTeaModel(ctx context.Context, totalIPs int, showAuth, showNetwork bool)
 		spinner:     s,
 		scanning:    true,
 		done:        false,
-		okList:      []string{},
-		authErrList: []string{},
-		networkList: []string{},
+		okList:      []ScanResult{},
+		authErrList: []ScanResult{},
+		networkList: []ScanResult{},
 		resultChan:  make(chan ScanResult, 100),
 		doneChan:    make(chan struct{}),
 		ctx:         teaCtx,
@@ -56,6 +83,7 @@ func NewTeaModel(ctx context.Context, totalIPs int, showAuth, showNetwork bool)
 		totalIPs:    totalIPs,
 		showAuth:    showAuth,
 		showNetwork: showNetwork,
+		knownHosts:  knownHosts,
 	}
 }
 
@@ -108,13 +136,18 @@ func (m *TeaModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case resultMsg:
 		// 收到扫描结果
 		m.totalScanned++
+		result := ScanResult(msg)
 		switch msg.Status {
-		case "ok":
-			m.okList = append(m.okList, msg.IP)
-		case "auth_error":
-			m.authErrList = append(m.authErrList, msg.IP)
-		case "network_error":
-			m.networkList = append(m.networkList, msg.IP)
+		case StatusOK:
+			m.okList = append(m.okList, result)
+		case StatusAuthError:
+			m.authErrList = append(m.authErrList, result)
+		case StatusNetworkError:
+			m.networkList = append(m.networkList, result)
+		case StatusHandshakeError:
+			m.handshakeList = append(m.handshakeList, result)
+		case StatusHostKeyChanged:
+			m.changedList = append(m.changedList, result)
 		}
 		// 继续等待下一个结果
 		return m, waitForResult(m.resultChan)
@@ -145,12 +178,47 @@ func (m *TeaModel) View() string {
 		sb.WriteString("扫描完成!\n\n")
 	}
 
+	// 显示主机密钥变化的 IP, 可能意味着主机被冒充, 总是显示
+	if len(m.changedList) > 0 {
+		changedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
+		sb.WriteString(changedStyle.Render("✗ 主机密钥变化:") + "\n")
+		for _, r := range m.changedList {
+			sb.WriteString(fmt.Sprintf("  %s\t%s\n", r.IP, r.HostKey.Fingerprint))
+		}
+		sb.WriteString("\n")
+	}
+
+	// 启用 known_hosts 校验时显示新的和一致的主机密钥
+	if m.knownHosts {
+		newArr, matchArr := groupHostKeys(m.GetResults())
+		if len(newArr) > 0 {
+			newStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Bold(true)
+			sb.WriteString(newStyle.Render("+ 新主机密钥:") + "\n")
+			for _, r := range newArr {
+				sb.WriteString(fmt.Sprintf("  %s\t%s %s", r.IP, r.HostKey.Type, r.HostKey.Fingerprint))
+				if r.HostKey.Status == HostKeyOtherType {
+					sb.WriteString("\t(known_hosts 中只有其他类型的密钥)")
+				}
+				sb.WriteString("\n")
+			}
+			sb.WriteString("\n")
+		}
+		if len(matchArr) > 0 {
+			matchStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+			sb.WriteString(matchStyle.Render("✓ 主机密钥一致:") + "\n")
+			for _, r := range matchArr {
+				sb.WriteString(fmt.Sprintf("  %s\t%s %s\n", r.IP, r.HostKey.Type, r.HostKey.Fingerprint))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
 	// 显示成功连接的 IP
 	if len(m.okList) > 0 {
 		successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
 		sb.WriteString(successStyle.Render("✓ 成功登录:") + "\n")
-		for _, ip := range m.okList {
-			sb.WriteString(fmt.Sprintf("  %s\n", ip))
+		for _, r := range m.okList {
+			sb.WriteString(fmt.Sprintf("  %s\t%s\t%s\n", r.IP, r.Credential, r.Method))
 		}
 		sb.WriteString("\n")
 	}
@@ -159,8 +227,8 @@ func (m *TeaModel) View() string {
 	if m.showAuth && len(m.authErrList) > 0 {
 		authStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
 		sb.WriteString(authStyle.Render("⚠ 认证失败:") + "\n")
-		for _, ip := range m.authErrList {
-			sb.WriteString(fmt.Sprintf("  %s\n", ip))
+		for _, r := range m.authErrList {
+			sb.WriteString(fmt.Sprintf("  %s\t%s\n", r.IP, r.Reason))
 		}
 		sb.WriteString("\n")
 	}
@@ -169,8 +237,18 @@ func (m *TeaModel) View() string {
 	if m.showNetwork && len(m.networkList) > 0 {
 		networkStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
 		sb.WriteString(networkStyle.Render("✗ 网络错误:") + "\n")
-		for _, ip := range m.networkList {
-			sb.WriteString(fmt.Sprintf("  %s\n", ip))
+		for _, r := range m.networkList {
+			sb.WriteString(fmt.Sprintf("  %s\t%s\n", r.IP, r.Reason))
+		}
+		sb.WriteString("\n")
+	}
+
+	// 显示握手失败的 IP（和网络错误使用同一个开关）
+	if m.showNetwork && len(m.handshakeList) > 0 {
+		handshakeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("13"))
+		sb.WriteString(handshakeStyle.Render("✗ 握手失败:") + "\n")
+		for _, r := range m.handshakeList {
+			sb.WriteString(fmt.Sprintf("  %s\t%s\t%s\n", r.IP, r.Reason, r.Banner))
 		}
 		sb.WriteString("\n")
 	}
@@ -202,6 +280,12 @@ func (m *TeaModel) MarkDone() {
 }
 
 // GetResults 获取扫描结果
-func (m *TeaModel) GetResults() (okList, authErrList, networkList []string) {
-	return m.okList, m.authErrList, m.networkList
+func (m *TeaModel) GetResults() []ScanResult {
+	results := make([]ScanResult, 0, len(m.okList)+len(m.authErrList)+len(m.networkList)+len(m.handshakeList)+len(m.changedList))
+	results = append(results, m.okList...)
+	results = append(results, m.authErrList...)
+	results = append(results, m.networkList...)
+	results = append(results, m.handshakeList...)
+	results = append(results, m.changedList...)
+	return results
 }
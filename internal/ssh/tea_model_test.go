@@ -0,0 +1,55 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTeaModelViewHostKeys(t *testing.T) {
+	results := []ScanResult{
+		{IP: "10.0.0.1:22", Status: StatusOK, Credential: &Credential{User: "root"}, HostKey: &HostKey{Type: "ssh-ed25519", Fingerprint: "SHA256:match", Status: HostKeyMatch}},
+		{IP: "10.0.0.2:22", Status: StatusAuthError, HostKey: &HostKey{Type: "ssh-ed25519", Fingerprint: "SHA256:new", Status: HostKeyNew}},
+		{IP: "10.0.0.3:22", Status: StatusAuthError, HostKey: &HostKey{Type: "ecdsa-sha2-nistp256", Fingerprint: "SHA256:other", Status: HostKeyOtherType}},
+		{IP: "10.0.0.4:22", Status: StatusHostKeyChanged, HostKey: &HostKey{Type: "ssh-ed25519", Fingerprint: "SHA256:changed", Status: HostKeyChanged}},
+		{IP: "10.0.0.5:22", Status: StatusNetworkError},
+	}
+
+	for _, knownHosts := range []bool{true, false} {
+		m := NewTeaModel(context.Background(), len(results), false, false, knownHosts)
+		for _, r := range results {
+			m.Update(resultMsg(r))
+		}
+		view := m.View()
+
+		if !strings.Contains(view, "10.0.0.4:22\tSHA256:changed") {
+			t.Errorf("knownHosts=%v: changed key not shown:\n%s", knownHosts, view)
+		}
+		for _, want := range []string{"SHA256:match", "SHA256:new", "SHA256:other", "只有其他类型的密钥"} {
+			if strings.Contains(view, want) != knownHosts {
+				t.Errorf("knownHosts=%v: contains %q = %v:\n%s", knownHosts, want, !knownHosts, view)
+			}
+		}
+	}
+}
+
+func TestGroupHostKeys(t *testing.T) {
+	results := []ScanResult{
+		{IP: "a", HostKey: &HostKey{Status: HostKeyMatch}},
+		{IP: "b", HostKey: &HostKey{Status: HostKeyNew}},
+		{IP: "c", HostKey: &HostKey{Status: HostKeyOtherType}},
+		{IP: "d", HostKey: &HostKey{Status: HostKeyChanged}},
+		{IP: "e", HostKey: &HostKey{}},
+		{IP: "f"},
+	}
+	newArr, matchArr := groupHostKeys(results)
+	if got := strings.Join(addrs(newArr), ","); got != "b,c" {
+		t.Errorf("new = %s, want b,c", got)
+	}
+	if got := strings.Join(addrs(matchArr), ","); got != "a" {
+		t.Errorf("match = %s, want a", got)
+	}
+	if got := strings.Join(addrs(hasHostKey(results)), ","); got != "a,b,c,d,e" {
+		t.Errorf("hasHostKey = %s, want a,b,c,d,e", got)
+	}
+}
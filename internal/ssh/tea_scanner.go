@@ -2,7 +2,6 @@ package ssh
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -11,11 +10,11 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/Runninginsilence1/scanner/internal/dumper"
-	"github.com/Runninginsilence1/scanner/internal/ip_gen"
+	"github.com/Runninginsilence1/scanner/internal/target"
 )
 
 // ScannerWithTea 使用 bubbletea 进行扫描
-func ScannerWithTea(ctx context.Context, prefix, start, end int, user, password string, opt Option, format string) {
+func ScannerWithTea(ctx context.Context, targets *target.List, creds []Credential, opt Option, format string) {
 	dumpType, err := dumper.GetType(format)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -25,17 +24,17 @@ func ScannerWithTea(ctx context.Context, prefix, start, end int, user, password
 	calTime := time.Now()
 
 	// 计算总 IP 数
-	totalIPs := end - start + 1
+	totalIPs := targets.Len(opt.port())
 
 	// 创建 bubbletea 模型
-	model := NewTeaModel(ctx, totalIPs, opt.ShowAuth, opt.ShowNetwork)
+	model := NewTeaModel(ctx, totalIPs, opt.ShowAuth, opt.ShowNetwork, opt.KnownHosts != nil)
 
 	// 启动 bubbletea 程序
 	p := tea.NewProgram(model)
 
 	// 在后台启动扫描
 	go func() {
-		runScan(model, prefix, start, end, user, password, opt)
+		runScan(model, targets, creds, opt)
 	}()
 
 	// 运行 bubbletea UI
@@ -47,23 +46,23 @@ func ScannerWithTea(ctx context.Context, prefix, start, end int, user, password
 
 	// 获取最终结果
 	teaModel := finalModel.(*TeaModel)
-	okArr, authArr, networkArr := teaModel.GetResults()
-
-	// 排序结果
-	sortByIpLast(okArr)
-	sortByIpLast(authArr)
-	sortByIpLast(networkArr)
+	results := teaModel.GetResults()
 
 	// 如果是 JSON 格式，输出 JSON
 	if dumpType == dumper.JSON {
-		output(okArr, authArr, networkArr, opt, dumpType)
+		output(results, opt, dumpType)
+	}
+	// 主机信息、命令和操作结果太长, 不在界面中显示, 扫描结束后统一输出
+	if dumpType == dumper.Console && (opt.Exec != "" || opt.Facts || opt.Action != nil) {
+		printDetailResults(results)
 	}
+	saveKnownHosts(results, opt)
 
 	fmt.Printf("\n扫描完成, 用时: %v ms\n", time.Since(calTime).Milliseconds())
 }
 
 // runScan 执行实际的扫描逻辑
-func runScan(model *TeaModel, prefix, start, end int, user, password string, opt Option) {
+func runScan(model *TeaModel, targets *target.List, creds []Credential, opt Option) {
 	ctx := model.GetContext()
 
 	// 设置默认并发数
@@ -73,7 +72,7 @@ func runScan(model *TeaModel, prefix, start, end int, user, password string, opt
 	}
 
 	// 创建任务队列
-	taskCh := make(chan int, 100)
+	taskCh := make(chan target.Target, 100)
 	var wg sync.WaitGroup
 
 	// 启动 worker pool
@@ -81,7 +80,7 @@ func runScan(model *TeaModel, prefix, start, end int, user, password string, opt
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for suffix := range taskCh {
+			for t := range taskCh {
 				// 检查 context 是否已取消
 				select {
 				case <-ctx.Done():
@@ -89,44 +88,25 @@ func runScan(model *TeaModel, prefix, start, end int, user, password string, opt
 				default:
 				}
 
-				var ipAddr string
-				if opt.Port > 0 && opt.Port != 22 {
-					ipAddr = ip_gen.GetSshAddrWithPort(prefix, suffix, opt.Port)
-				} else {
-					ipAddr = ip_gen.GetSshAddr(prefix, suffix)
-				}
+				ipAddr := t.Addr(opt.port())
 
 				// Loop 模式不适用于 bubbletea，跳过
 				if opt.Loop {
 					continue
 				}
 
-				err := TryConnectServerV2(ctx, ipAddr, password, user, opt.EnablePubKey)
-				if err == nil {
-					model.SendResult(ScanResult{IP: ipAddr, Status: "ok"})
-				} else if errors.Is(err, AuthError) {
-					model.SendResult(ScanResult{IP: ipAddr, Status: "auth_error"})
-				} else if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				result, ok := scanHost(ctx, ipAddr, creds, opt)
+				if !ok {
 					// context 取消，不记录错误
 					return
-				} else {
-					model.SendResult(ScanResult{IP: ipAddr, Status: "network_error"})
 				}
+				model.SendResult(result)
 			}
 		}()
 	}
 
 	// 发送任务到任务队列
-	go func() {
-		for i := start; i <= end; i++ {
-			select {
-			case <-ctx.Done():
-				break
-			case taskCh <- i:
-			}
-		}
-		close(taskCh)
-	}()
+	go sendTasks(ctx, targets, opt.port(), taskCh)
 
 	// 等待所有 worker 完成
 	wg.Wait()
@@ -134,3 +114,15 @@ func runScan(model *TeaModel, prefix, start, end int, user, password string, opt
 	// 标记扫描完成
 	model.MarkDone()
 }
+
+// printDetailResults 输出成功登录的主机的主机信息、命令和操作结果
+func printDetailResults(results []ScanResult) {
+	sortResults(results)
+	for _, r := range results {
+		if r.Status != StatusOK {
+			continue
+		}
+		fmt.Printf("\n%v\n", r.IP)
+		printDetails(r)
+	}
+}
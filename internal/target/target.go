@@ -0,0 +1,401 @@
+package target
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// 扫描目标解析
+// 支持的格式(多个用逗号分隔):
+//   - CIDR:     10.20.0.0/16, 2001:db8::/120, fe80::%eth0/120
+//   - 范围:     10.0.0.5-10.0.1.20 或 10.0.0.5-20, 2001:db8::1-2001:db8::ff
+//   - 单个主机: 172.16.3.4, 2001:db8::1, fe80::1%eth0
+//   - 主机名:   nas.local
+// 每一项都可以用 :port 指定端口, 例如 10.1.2.3:2222, IPv6 需要加方括号: [2001:db8::1]:2222
+
+// 单个条目最多展开的主机数, 防止误输入 /8 或 /64 之类的网段
+const maxItemHosts = 1 << 20
+
+var (
+	ErrEmptySpec   = errors.New("empty target spec")
+	ErrInvalidSpec = errors.New("invalid target spec")
+	ErrTooLarge    = fmt.Errorf("target spec expands to more than %d hosts", maxItemHosts)
+)
+
+// Target 表示一个扫描目标
+type Target struct {
+	Host string // IP 或主机名
+	Port int    // 单独指定的端口, 为 0 时使用扫描器的默认端口
+}
+
+// Addr 返回 host:port 形式的地址, 没有单独指定端口时使用 defaultPort
+func (t Target) Addr(defaultPort int) string {
+	port := defaultPort
+	if t.Port > 0 {
+		port = t.Port
+	}
+	return net.JoinHostPort(t.Host, strconv.Itoa(port))
+}
+
+// item 是目标列表中的一个条目
+type item interface {
+	all() iter.Seq[Target]
+	len() int
+	String() string
+}
+
+// List 是解析后的目标列表, 通过 All 惰性展开
+type List struct {
+	items []item
+}
+
+// Parse 解析目标描述字符串
+func Parse(spec string) (*List, error) {
+	l := &List{}
+	if err := l.add(spec); err != nil {
+		return nil, err
+	}
+	if len(l.items) == 0 {
+		return nil, ErrEmptySpec
+	}
+	return l, nil
+}
+
+// ParseReader 逐行读取目标, 每行的格式和 Parse 相同, # 之后的内容为注释
+func ParseReader(r io.Reader) (*List, error) {
+	l := &List{}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if err := l.add(line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(l.items) == 0 {
+		return nil, ErrEmptySpec
+	}
+	return l, nil
+}
+
+// Merge 把 other 中的目标追加到 l 之后
+func (l *List) Merge(other *List) {
+	l.items = append(l.items, other.items...)
+}
+
+func (l *List) add(spec string) error {
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		it, err := parseItem(field)
+		if err != nil {
+			return err
+		}
+		l.items = append(l.items, it)
+	}
+	return nil
+}
+
+// FromLegacy 兼容旧的 --prefix/--start/--end 参数, 即 192.168.<prefix>.<start> 到 192.168.<prefix>.<end>
+func FromLegacy(prefix, start, end int) (*List, error) {
+	return Parse(fmt.Sprintf("192.168.%d.%d-192.168.%d.%d", prefix, start, prefix, end))
+}
+
+// All 按顺序惰性展开所有目标, 多个条目展开出同一个地址时只返回第一次出现的目标;
+// 没有单独指定端口的目标按 defaultPort 比较, 即 10.1.2.3 和 10.1.2.3:22 在默认端口为 22 时是同一个目标.
+// defaultPort 为 0 时忽略端口, 只按主机去重, 用于 ping 和端口扫描这类只关心主机的命令
+func (l *List) All(defaultPort int) iter.Seq[Target] {
+	return func(yield func(Target) bool) {
+		if len(l.items) == 1 {
+			for t := range l.items[0].all() {
+				if !yield(t) {
+					return
+				}
+			}
+			return
+		}
+
+		seen := make(map[Target]struct{})
+		for _, it := range l.items {
+			for t := range it.all() {
+				key := Target{Host: t.Host}
+				if defaultPort != 0 {
+					key.Port = cmp.Or(t.Port, defaultPort)
+				}
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+				if !yield(t) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Len 返回去重后的目标总数, defaultPort 的含义和 All 相同
+func (l *List) Len(defaultPort int) int {
+	if len(l.items) == 1 {
+		return l.items[0].len()
+	}
+	n := 0
+	for range l.All(defaultPort) {
+		n++
+	}
+	return n
+}
+
+func (l *List) String() string {
+	parts := make([]string, 0, len(l.items))
+	for _, it := range l.items {
+		parts = append(parts, it.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+func parseItem(field string) (item, error) {
+	s, port, err := splitPort(field)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(s, "/") {
+		return parseCIDR(s, port)
+	}
+	if from, to, ok := strings.Cut(s, "-"); ok {
+		// 主机名里也可能有 '-', 左边是 IP 时才当作范围
+		if start, err := netip.ParseAddr(from); err == nil {
+			return parseRange(s, start, to, port)
+		}
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		addr, zone := splitZone(addr)
+		return &rangeItem{from: addr, to: addr, zone: zone, port: port}, nil
+	}
+	if !validHostname(s) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSpec, s)
+	}
+	return &hostItem{name: s, port: port}, nil
+}
+
+// splitPort 拆分条目末尾的 :port, 没有指定端口时返回 0
+// IPv6 条目必须写成 [addr]:port 的形式才能指定端口
+func splitPort(field string) (string, int, error) {
+	var s, portStr string
+	if strings.HasPrefix(field, "[") {
+		end := strings.Index(field, "]")
+		if end < 0 {
+			return "", 0, fmt.Errorf("%w: %q: missing ']'", ErrInvalidSpec, field)
+		}
+		s, portStr = field[1:end], field[end+1:]
+		if portStr == "" {
+			return s, 0, nil
+		}
+		if !strings.HasPrefix(portStr, ":") {
+			return "", 0, fmt.Errorf("%w: %q", ErrInvalidSpec, field)
+		}
+		portStr = portStr[1:]
+	} else {
+		if strings.Count(field, ":") != 1 {
+			return field, 0, nil
+		}
+		s, portStr, _ = strings.Cut(field, ":")
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("%w: %q: invalid port", ErrInvalidSpec, field)
+	}
+	return s, port, nil
+}
+
+func parseCIDR(s string, port int) (item, error) {
+	// netip.ParsePrefix 不支持 zone, 先拆出来: fe80::%eth0/120
+	addrStr, bitsStr, _ := strings.Cut(s, "/")
+	addr, err := netip.ParseAddr(addrStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidSpec, s, err)
+	}
+	addr, zone := splitZone(addr)
+	prefix, err := netip.ParsePrefix(addr.String() + "/" + bitsStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidSpec, s, err)
+	}
+	prefix = prefix.Masked()
+	from := prefix.Addr()
+	to := lastAddr(prefix)
+	// IPv4 网段去掉网络地址和广播地址, /31 和 /32 除外
+	if from.Is4() && prefix.Bits() <= 30 {
+		from = from.Next()
+		to = to.Prev()
+	}
+	it := &rangeItem{from: from, to: to, zone: zone, port: port, label: s}
+	if it.len() > maxItemHosts {
+		return nil, fmt.Errorf("%w: %q", ErrTooLarge, s)
+	}
+	return it, nil
+}
+
+func parseRange(s string, from netip.Addr, toStr string, port int) (item, error) {
+	from, zone := splitZone(from)
+	to, err := netip.ParseAddr(toStr)
+	if err != nil {
+		// 简写形式: 10.0.0.5-20, 只支持 IPv4
+		n, convErr := strconv.Atoi(toStr)
+		if !from.Is4() || convErr != nil || n < 0 || n > 255 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSpec, s)
+		}
+		b := from.As4()
+		b[3] = byte(n)
+		to = netip.AddrFrom4(b)
+	}
+	to, toZone := splitZone(to)
+	if toZone != "" && toZone != zone {
+		return nil, fmt.Errorf("%w: %q: mismatched zones", ErrInvalidSpec, s)
+	}
+	if to.Is4() != from.Is4() {
+		return nil, fmt.Errorf("%w: %q: mixed address families", ErrInvalidSpec, s)
+	}
+	if to.Less(from) {
+		return nil, fmt.Errorf("%w: %q: range end is before start", ErrInvalidSpec, s)
+	}
+	it := &rangeItem{from: from, to: to, zone: zone, port: port, label: s}
+	if it.len() > maxItemHosts {
+		return nil, fmt.Errorf("%w: %q", ErrTooLarge, s)
+	}
+	return it, nil
+}
+
+// splitZone 拆分 IPv6 地址的 zone, 例如 fe80::1%eth0
+// IPv4-mapped 地址统一转换成 IPv4
+func splitZone(addr netip.Addr) (netip.Addr, string) {
+	zone := addr.Zone()
+	return addr.WithZone("").Unmap(), zone
+}
+
+// lastAddr 返回网段中的最后一个地址
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	for i := len(b) - 1; i >= 0 && hostBits > 0; i-- {
+		n := min(hostBits, 8)
+		b[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+func validHostname(s string) bool {
+	if len(s) > 253 {
+		return false
+	}
+	labels := strings.Split(s, ".")
+	// 最后一段全是数字时不是主机名, 而是写错的 IP, 例如 10.0.0.300
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// rangeItem 是一段连续的地址
+type rangeItem struct {
+	from, to netip.Addr
+	zone     string // IPv6 链路本地地址的 zone, 例如 eth0
+	port     int
+	label    string
+}
+
+func (r *rangeItem) all() iter.Seq[Target] {
+	return func(yield func(Target) bool) {
+		if r.to.Less(r.from) {
+			return
+		}
+		for a := r.from; a.IsValid(); a = a.Next() {
+			if !yield(Target{Host: a.WithZone(r.zone).String(), Port: r.port}) {
+				return
+			}
+			if a == r.to {
+				return
+			}
+		}
+	}
+}
+
+func (r *rangeItem) len() int {
+	if r.to.Less(r.from) {
+		return 0
+	}
+	// 只需要比较低 64 位, 高位不同的范围肯定超过了 maxItemHosts
+	f, t := r.from.As16(), r.to.As16()
+	if binary.BigEndian.Uint64(f[:8]) != binary.BigEndian.Uint64(t[:8]) {
+		return math.MaxInt
+	}
+	diff := binary.BigEndian.Uint64(t[8:]) - binary.BigEndian.Uint64(f[8:])
+	if diff >= maxItemHosts {
+		return math.MaxInt
+	}
+	return int(diff) + 1
+}
+
+func (r *rangeItem) String() string {
+	s := r.label
+	if s == "" {
+		s = r.from.WithZone(r.zone).String()
+		if r.from != r.to {
+			s += "-" + r.to.WithZone(r.zone).String()
+		}
+	}
+	if r.port != 0 && r.from.Is6() {
+		s = "[" + s + "]"
+	}
+	return withPort(s, r.port)
+}
+
+// hostItem 是单个主机名
+type hostItem struct {
+	name string
+	port int
+}
+
+func (h *hostItem) all() iter.Seq[Target] {
+	return func(yield func(Target) bool) {
+		yield(Target{Host: h.name, Port: h.port})
+	}
+}
+
+func (h *hostItem) len() int { return 1 }
+
+func (h *hostItem) String() string { return withPort(h.name, h.port) }
+
+func withPort(s string, port int) string {
+	if port == 0 {
+		return s
+	}
+	return s + ":" + strconv.Itoa(port)
+}
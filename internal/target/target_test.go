@@ -0,0 +1,230 @@
+package target
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func collect(l *List) []Target {
+	var targets []Target
+	for t := range l.All(22) {
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+func hosts(hosts ...string) []Target {
+	targets := make([]Target, 0, len(hosts))
+	for _, h := range hosts {
+		targets = append(targets, Target{Host: h})
+	}
+	return targets
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want []Target
+	}{
+		{"single ipv4", "172.16.3.4", hosts("172.16.3.4")},
+		{"cidr /30 drops network and broadcast", "10.0.0.0/30", hosts("10.0.0.1", "10.0.0.2")},
+		{"cidr /31 keeps both", "10.0.0.0/31", hosts("10.0.0.0", "10.0.0.1")},
+		{"cidr /32", "10.0.0.7/32", hosts("10.0.0.7")},
+		{"cidr not masked", "10.0.0.5/30", hosts("10.0.0.5", "10.0.0.6")},
+		{"range", "10.0.0.254-10.0.1.1", hosts("10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1")},
+		{"range shorthand", "10.0.0.5-7", hosts("10.0.0.5", "10.0.0.6", "10.0.0.7")},
+		{"range single", "10.0.0.5-5", hosts("10.0.0.5")},
+		{"ipv6 cidr", "2001:db8::/127", hosts("2001:db8::", "2001:db8::1")},
+		{"ipv6 range", "2001:db8::1-2001:db8::3", hosts("2001:db8::1", "2001:db8::2", "2001:db8::3")},
+		{"ipv6 zone", "fe80::1%eth0", hosts("fe80::1%eth0")},
+		{"ipv6 zone cidr", "fe80::%eth0/127", hosts("fe80::%eth0", "fe80::1%eth0")},
+		{"ipv4-mapped ipv6", "::ffff:10.0.0.1", hosts("10.0.0.1")},
+		{"hostname", "nas.local", hosts("nas.local")},
+		{"hyphenated hostname", "core-sw-01.lan", hosts("core-sw-01.lan")},
+		{"hostname starting with digits", "10-0-0-1.example.com", hosts("10-0-0-1.example.com")},
+		{"ipv4 port", "10.1.2.3:2222", []Target{{Host: "10.1.2.3", Port: 2222}}},
+		{"ipv6 port", "[2001:db8::1]:2222", []Target{{Host: "2001:db8::1", Port: 2222}}},
+		{"ipv6 brackets without port", "[2001:db8::1]", hosts("2001:db8::1")},
+		{"ipv6 zone port", "[fe80::1%eth0]:22", []Target{{Host: "fe80::1%eth0", Port: 22}}},
+		{"cidr port", "10.0.0.0/31:2200", []Target{{Host: "10.0.0.0", Port: 2200}, {Host: "10.0.0.1", Port: 2200}}},
+		{"hostname port", "nas.local:8022", []Target{{Host: "nas.local", Port: 8022}}},
+		{"list with spaces", " 10.0.0.1 , nas.local,", hosts("10.0.0.1", "nas.local")},
+		{"overlapping items", "10.0.0.1-3,10.0.0.2", hosts("10.0.0.1", "10.0.0.2", "10.0.0.3")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			got := collect(l)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+			if l.Len(22) != len(tt.want) {
+				t.Errorf("Len(22) = %d, want %d", l.Len(22), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want error
+	}{
+		{"empty", "", ErrEmptySpec},
+		{"only commas", " , ,", ErrEmptySpec},
+		{"bad cidr bits", "10.0.0.0/33", ErrInvalidSpec},
+		{"bad cidr addr", "10.0.0/24", ErrInvalidSpec},
+		{"range end before start", "10.0.0.9-10.0.0.1", ErrInvalidSpec},
+		{"shorthand out of range", "10.0.0.5-256", ErrInvalidSpec},
+		{"shorthand before start", "10.0.0.5-4", ErrInvalidSpec},
+		{"ipv6 shorthand", "2001:db8::1-5", ErrInvalidSpec},
+		{"mixed families", "10.0.0.1-2001:db8::1", ErrInvalidSpec},
+		{"mismatched zones", "fe80::1%eth0-fe80::2%eth1", ErrInvalidSpec},
+		{"port zero", "10.0.0.1:0", ErrInvalidSpec},
+		{"port too large", "10.0.0.1:65536", ErrInvalidSpec},
+		{"port not a number", "10.0.0.1:ssh", ErrInvalidSpec},
+		{"missing bracket", "[2001:db8::1:22", ErrInvalidSpec},
+		{"garbage after bracket", "[2001:db8::1]x22", ErrInvalidSpec},
+		{"hostname leading hyphen", "-bad.lan", ErrInvalidSpec},
+		{"hostname trailing hyphen", "bad-.lan", ErrInvalidSpec},
+		{"hostname empty label", "bad..lan", ErrInvalidSpec},
+		{"hostname invalid char", "bad!host", ErrInvalidSpec},
+		{"ip with octet out of range", "10.0.0.300", ErrInvalidSpec},
+		{"ipv4 /11 too large", "10.0.0.0/11", ErrTooLarge},
+		{"ipv6 /64 too large", "2001:db8::/64", ErrTooLarge},
+		{"range too large", "10.0.0.0-10.16.0.1", ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.spec)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Parse(%q) error = %v, want %v", tt.spec, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxItemHosts(t *testing.T) {
+	// 正好 maxItemHosts 个地址的网段和范围可以解析, 更大的见 TestParseErrors
+	for _, spec := range []string{"10.0.0.0/12", "2001:db8::/108", "10.0.0.0-10.15.255.255"} {
+		l, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", spec, err)
+		}
+		if l.Len(22) > maxItemHosts {
+			t.Errorf("Parse(%q).Len(22) = %d, want <= %d", spec, l.Len(22), maxItemHosts)
+		}
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	input := `# 机房 A
+10.0.0.1
+10.0.0.2:2222   # 单独的端口
+
+[2001:db8::1]:22, nas.local
+`
+	l, err := ParseReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Target{
+		{Host: "10.0.0.1"},
+		{Host: "10.0.0.2", Port: 2222},
+		{Host: "2001:db8::1", Port: 22},
+		{Host: "nas.local"},
+	}
+	if got := collect(l); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	_, err = ParseReader(strings.NewReader("10.0.0.1\n10.0.0.300\n"))
+	if !errors.Is(err, ErrInvalidSpec) || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("error = %v, want line 2 invalid spec", err)
+	}
+
+	if _, err = ParseReader(strings.NewReader("# only comments\n\n")); !errors.Is(err, ErrEmptySpec) {
+		t.Errorf("error = %v, want %v", err, ErrEmptySpec)
+	}
+}
+
+func TestTargetAddr(t *testing.T) {
+	tests := []struct {
+		target Target
+		want   string
+	}{
+		{Target{Host: "10.0.0.1"}, "10.0.0.1:22"},
+		{Target{Host: "10.0.0.1", Port: 2222}, "10.0.0.1:2222"},
+		{Target{Host: "2001:db8::1"}, "[2001:db8::1]:22"},
+		{Target{Host: "fe80::1%eth0"}, "[fe80::1%eth0]:22"},
+	}
+	for _, tt := range tests {
+		if got := tt.target.Addr(22); got != tt.want {
+			t.Errorf("%v.Addr(22) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	l, err := Parse("10.0.0.0/24,10.0.0.5-20,[2001:db8::1]:2222,nas.local:22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "10.0.0.0/24, 10.0.0.5-20, [2001:db8::1]:2222, nas.local:22"
+	if got := l.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestAllDedupByAddr(t *testing.T) {
+	l, err := Parse("10.1.2.3,10.1.2.3:22,10.1.2.3:2222,[2001:db8::1]:22,2001:db8::1,10.1.2.2-4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		defaultPort int
+		want        []Target
+	}{
+		{22, []Target{
+			{Host: "10.1.2.3"},
+			{Host: "10.1.2.3", Port: 2222},
+			{Host: "2001:db8::1", Port: 22},
+			{Host: "10.1.2.2"},
+			{Host: "10.1.2.4"},
+		}},
+		{2222, []Target{
+			{Host: "10.1.2.3"},
+			{Host: "10.1.2.3", Port: 22},
+			{Host: "2001:db8::1", Port: 22},
+			{Host: "2001:db8::1"},
+			{Host: "10.1.2.2"},
+			{Host: "10.1.2.4"},
+		}},
+		// 只按主机去重
+		{0, []Target{
+			{Host: "10.1.2.3"},
+			{Host: "2001:db8::1", Port: 22},
+			{Host: "10.1.2.2"},
+			{Host: "10.1.2.4"},
+		}},
+	}
+	for _, tt := range tests {
+		var got []Target
+		for target := range l.All(tt.defaultPort) {
+			got = append(got, target)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("All(%d) = %v, want %v", tt.defaultPort, got, tt.want)
+		}
+		if n := l.Len(tt.defaultPort); n != len(tt.want) {
+			t.Errorf("Len(%d) = %d, want %d", tt.defaultPort, n, len(tt.want))
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package timeout
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// 所有扫描器共用的超时设置
+// 自适应模式下按子网(IPv4 /24, IPv6 /64)记录连接的往返时间, 和 TCP 的 RTO 一样用平滑 RTT 和 RTT 偏差估算超时,
+// 慢速链路(例如 VPN)上自动放宽超时, 快速的局域网上缩短连接超时
+
+// 自适应超时的范围: 连接超时在 [base/4, base*4] 之间, 握手和命令超时在 [base, base*4] 之间
+const maxScale = 4
+
+// SSH 握手和认证需要若干个往返, 按 8 个 RTT 估算
+const handshakeRounds = 8
+
+// Config 是超时设置, 为 0 的字段使用各扫描器自己的默认值; nil 表示全部使用默认值
+type Config struct {
+	Connect   time.Duration // TCP 连接超时
+	Handshake time.Duration // 协议握手超时: SSH 的握手和认证, detect 的 HTTP 请求
+	Command   time.Duration // 登录后远程命令的超时
+
+	adaptive bool
+	mu       sync.Mutex
+	subnets  map[string]*rttStats
+}
+
+// New 创建超时设置, adaptive 为 true 时按子网的 RTT 调整超时
+func New(connect, handshake, command time.Duration, adaptive bool) *Config {
+	return &Config{
+		Connect:   connect,
+		Handshake: handshake,
+		Command:   command,
+		adaptive:  adaptive,
+		subnets:   make(map[string]*rttStats),
+	}
+}
+
+// ConnectTimeout 返回连接 host 的超时时间, def 是扫描器的默认值
+func (c *Config) ConnectTimeout(host string, def time.Duration) time.Duration {
+	if c == nil {
+		return def
+	}
+	base := pick(c.Connect, def)
+	if s, ok := c.stats(host); ok {
+		return s.timeout(1, base/maxScale, base*maxScale)
+	}
+	return base
+}
+
+// HandshakeTimeout 返回和 host 完成握手的超时时间, def 是扫描器的默认值
+func (c *Config) HandshakeTimeout(host string, def time.Duration) time.Duration {
+	if c == nil {
+		return def
+	}
+	base := pick(c.Handshake, def)
+	if s, ok := c.stats(host); ok {
+		return s.timeout(handshakeRounds, base, base*maxScale)
+	}
+	return base
+}
+
+// CommandTimeout 返回在 host 上执行命令的超时时间, def 是扫描器的默认值
+func (c *Config) CommandTimeout(host string, def time.Duration) time.Duration {
+	if c == nil {
+		return def
+	}
+	base := pick(c.Command, def)
+	if s, ok := c.stats(host); ok {
+		return s.timeout(handshakeRounds, base, base*maxScale)
+	}
+	return base
+}
+
+// Observe 记录一次到 host 的往返时间, 例如 TCP 连接成功或者被拒绝所用的时间; 非自适应模式下忽略
+func (c *Config) Observe(host string, rtt time.Duration) {
+	if c == nil || !c.adaptive {
+		return
+	}
+	key := subnet(host)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.subnets[key]
+	if !ok {
+		c.subnets[key] = &rttStats{srtt: rtt, rttvar: rtt / 2}
+		return
+	}
+	s.update(rtt)
+}
+
+func (c *Config) stats(host string) (rttStats, bool) {
+	if !c.adaptive {
+		return rttStats{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.subnets[subnet(host)]
+	if !ok {
+		return rttStats{}, false
+	}
+	return *s, true
+}
+
+func pick(value, def time.Duration) time.Duration {
+	if value > 0 {
+		return value
+	}
+	return def
+}
+
+// rttStats 是 RFC 6298 中的平滑 RTT 和 RTT 偏差
+type rttStats struct {
+	srtt   time.Duration
+	rttvar time.Duration
+}
+
+func (s *rttStats) update(rtt time.Duration) {
+	diff := s.srtt - rtt
+	if diff < 0 {
+		diff = -diff
+	}
+	s.rttvar = (3*s.rttvar + diff) / 4
+	s.srtt = (7*s.srtt + rtt) / 8
+}
+
+// timeout 返回 rounds 个往返时间加上 4 倍偏差, 限制在 [lo, hi] 之间
+func (s rttStats) timeout(rounds int, lo, hi time.Duration) time.Duration {
+	return min(max(time.Duration(rounds)*s.srtt+4*s.rttvar, lo), hi)
+}
+
+// subnet 返回 host 所在的子网, 主机名原样返回
+func subnet(host string) string {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	bits := 64
+	if addr.Unmap().Is4() {
+		addr, bits = addr.Unmap(), 24
+	}
+	prefix, err := addr.WithZone("").Prefix(bits)
+	if err != nil {
+		return host
+	}
+	return prefix.String()
+}
@@ -0,0 +1,134 @@
+package timeout
+
+import (
+	"testing"
+	"time"
+)
+
+const ms = time.Millisecond
+
+func TestRTTStatsUpdate(t *testing.T) {
+	c := New(0, 0, 0, true)
+	c.Observe("10.0.0.1", 100*ms)
+	s, ok := c.stats("10.0.0.1")
+	// 第一个样本: SRTT = R, RTTVAR = R/2
+	if !ok || s.srtt != 100*ms || s.rttvar != 50*ms {
+		t.Fatalf("after first sample: %+v", s)
+	}
+
+	// RTTVAR = 3/4 RTTVAR + 1/4 |SRTT - R|, SRTT = 7/8 SRTT + 1/8 R
+	c.Observe("10.0.0.1", 200*ms)
+	s, _ = c.stats("10.0.0.1")
+	if want := (3*50*ms + 100*ms) / 4; s.rttvar != want {
+		t.Errorf("rttvar = %v, want %v", s.rttvar, want)
+	}
+	if want := (7*100*ms + 200*ms) / 8; s.srtt != want {
+		t.Errorf("srtt = %v, want %v", s.srtt, want)
+	}
+
+	// 样本比 SRTT 小时偏差也是正数
+	c.Observe("10.0.0.1", 0)
+	s, _ = c.stats("10.0.0.1")
+	if s.rttvar <= 0 || s.srtt >= 112500*time.Microsecond {
+		t.Errorf("after smaller sample: %+v", s)
+	}
+}
+
+func TestAdaptiveTimeouts(t *testing.T) {
+	const base = time.Second
+	tests := []struct {
+		name      string
+		rtt       time.Duration
+		connect   time.Duration
+		handshake time.Duration
+	}{
+		// 连接: SRTT + 4*RTTVAR = 3R, 限制在 [base/4, base*4]
+		// 握手和命令: 8*SRTT + 4*RTTVAR = 10R, 限制在 [base, base*4]
+		{"normal", 100 * ms, 300 * ms, base},
+		{"floor", ms, base / maxScale, base},
+		{"slow link", 200 * ms, 600 * ms, 2 * base},
+		{"ceiling", 10 * time.Second, base * maxScale, base * maxScale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(base, base, base, true)
+			c.Observe("192.168.1.10", tt.rtt)
+			if got := c.ConnectTimeout("192.168.1.10", 5*time.Second); got != tt.connect {
+				t.Errorf("ConnectTimeout = %v, want %v", got, tt.connect)
+			}
+			if got := c.HandshakeTimeout("192.168.1.10", 5*time.Second); got != tt.handshake {
+				t.Errorf("HandshakeTimeout = %v, want %v", got, tt.handshake)
+			}
+			if got := c.CommandTimeout("192.168.1.10", 5*time.Second); got != tt.handshake {
+				t.Errorf("CommandTimeout = %v, want %v", got, tt.handshake)
+			}
+		})
+	}
+}
+
+func TestFallback(t *testing.T) {
+	var nilConfig *Config
+	nilConfig.Observe("10.0.0.1", time.Second)
+	if got := nilConfig.ConnectTimeout("10.0.0.1", 500*ms); got != 500*ms {
+		t.Errorf("nil config: %v, want default", got)
+	}
+
+	// 没有样本时使用设置的值, 没有设置时使用扫描器的默认值
+	c := New(2*time.Second, 0, 0, true)
+	if got := c.ConnectTimeout("10.0.0.1", 500*ms); got != 2*time.Second {
+		t.Errorf("ConnectTimeout before sample = %v, want 2s", got)
+	}
+	if got := c.HandshakeTimeout("10.0.0.1", 3*time.Second); got != 3*time.Second {
+		t.Errorf("HandshakeTimeout before sample = %v, want 3s", got)
+	}
+	if got := c.CommandTimeout("10.0.0.1", 10*time.Second); got != 10*time.Second {
+		t.Errorf("CommandTimeout before sample = %v, want 10s", got)
+	}
+
+	// 非自适应模式忽略样本
+	c = New(time.Second, 0, 0, false)
+	c.Observe("10.0.0.1", ms)
+	if got := c.ConnectTimeout("10.0.0.1", 500*ms); got != time.Second {
+		t.Errorf("non-adaptive ConnectTimeout = %v, want 1s", got)
+	}
+}
+
+func TestSubnet(t *testing.T) {
+	tests := []struct {
+		host, want string
+	}{
+		{"10.1.2.3", "10.1.2.0/24"},
+		{"10.1.2.255", "10.1.2.0/24"},
+		{"::ffff:10.1.2.3", "10.1.2.0/24"},
+		{"2001:db8:0:1::1", "2001:db8:0:1::/64"},
+		{"2001:db8:0:1:ffff::1", "2001:db8:0:1::/64"},
+		{"fe80::1%eth0", "fe80::/64"},
+		{"nas.local", "nas.local"},
+	}
+	for _, tt := range tests {
+		if got := subnet(tt.host); got != tt.want {
+			t.Errorf("subnet(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestPerSubnet(t *testing.T) {
+	c := New(time.Second, 0, 0, true)
+	c.Observe("10.1.2.3", ms)
+	c.Observe("fe80::1%eth0", ms)
+
+	tests := []struct {
+		host string
+		want time.Duration
+	}{
+		{"10.1.2.200", 250 * ms},  // 同一个 /24
+		{"10.1.3.1", time.Second}, // 其他子网没有样本
+		{"fe80::2%eth1", 250 * ms},
+		{"fe80:0:0:1::1", time.Second},
+	}
+	for _, tt := range tests {
+		if got := c.ConnectTimeout(tt.host, 0); got != tt.want {
+			t.Errorf("ConnectTimeout(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
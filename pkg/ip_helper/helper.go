@@ -1,13 +1,48 @@
 package ip_helper
 
 import (
-	"strings"
-
-	"github.com/spf13/cast"
+	"cmp"
+	"net"
+	"net/netip"
+	"strconv"
 )
 
-func GetIpLast(ip string) int {
-	ipArr := strings.Split(ip, ".")
-	n := len(ipArr)
-	return cast.ToInt(ipArr[n-1])
+// Compare 比较两个地址, 用于结果排序
+// 地址可以是 IP, 也可以是 host:port 或 [ipv6]:port 的形式;
+// IP 按数值排序(IPv4 在 IPv6 之前), 主机名排在 IP 之后并按字典序排序, 地址相同时再比较端口
+func Compare(a, b string) int {
+	hostA, portA := splitHostPort(a)
+	hostB, portB := splitHostPort(b)
+
+	ipA, errA := netip.ParseAddr(hostA)
+	ipB, errB := netip.ParseAddr(hostB)
+	switch {
+	case errA == nil && errB == nil:
+		if c := ipA.Compare(ipB); c != 0 {
+			return c
+		}
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		if c := cmp.Compare(hostA, hostB); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(portA, portB)
+}
+
+// Less 在 a 应该排在 b 之前时返回 true
+func Less(a, b string) bool {
+	return Compare(a, b) < 0
+}
+
+func splitHostPort(addr string) (string, int) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, 0
+	}
+	port, _ := strconv.Atoi(portStr)
+	return host, port
 }